@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nareix/joy5/codec/aac"
+)
+
+// getAudioGapThreshold 获取音频断流阈值（从配置读取，默认500ms）
+func getAudioGapThreshold() time.Duration {
+	if globalConfig != nil && globalConfig.Exporter.AudioGapThresholdMs > 0 {
+		return time.Duration(globalConfig.Exporter.AudioGapThresholdMs) * time.Millisecond
+	}
+	return 500 * time.Millisecond // 默认值
+}
+
+// AudioInfo 从 AudioSpecificConfig 解析出的音频参数
+type AudioInfo struct {
+	Codec      string // AAC
+	ObjectType int    // audioObjectType，例如 2 = AAC-LC，5 = HE-AAC
+	Profile    string // 例如 LC / HE-AAC / HE-AACv2
+	SampleRate int    // 采样率（Hz）
+	Channels   int    // 声道数
+}
+
+// AudioStats 音频分析结果（本次检查的值）
+type AudioStats struct {
+	Info       AudioInfo
+	BitrateBps float64 // 音频码率（按 AAC 帧负载和音频 DTS 跨度计算）
+	JitterMs   float64 // 音频包到达间隔抖动（RFC 3550 算法）
+	Gaps       int     // 相邻音频包 DTS 间隔超过阈值的次数
+	GapMaxMs   float64 // 最大的相邻音频包 DTS 间隔
+}
+
+// aacProfileName AAC audioObjectType 对应的名称
+func aacProfileName(objectType int) string {
+	switch objectType {
+	case aac.AOT_AAC_MAIN:
+		return "Main"
+	case aac.AOT_AAC_LC:
+		return "LC"
+	case aac.AOT_AAC_SSR:
+		return "SSR"
+	case aac.AOT_AAC_LTP:
+		return "LTP"
+	case aac.AOT_SBR:
+		return "HE-AAC"
+	case 29: // PS
+		return "HE-AACv2"
+	case aac.AOT_ER_AAC_LD:
+		return "LD"
+	case 39: // ER AAC ELD
+		return "ELD"
+	}
+	return fmt.Sprintf("%d", objectType)
+}
+
+// parseAACDecoderConfig 解析 AudioSpecificConfig（FLV AAC sequence header / SDP config / ADTS 生成的配置）
+func parseAACDecoderConfig(data []byte) (AudioInfo, error) {
+	config, err := aac.ParseMPEG4AudioConfigBytes(data)
+	if err != nil {
+		return AudioInfo{}, fmt.Errorf("解析 AAC 配置失败: %w", err)
+	}
+	return AudioInfo{
+		Codec:      "AAC",
+		ObjectType: int(config.ObjectType),
+		Profile:    aacProfileName(int(config.ObjectType)),
+		SampleRate: config.SampleRate,
+		Channels:   config.ChannelLayout.Count(),
+	}, nil
+}
+
+// audioSampler 统计音频包的码率、到达抖动和断流
+type audioSampler struct {
+	gapThreshold time.Duration
+	segmented    bool // 包按分片成批到达（HLS）：到达间隔不反映发送节奏，不计算抖动
+
+	bytes    int64
+	firstDTS time.Duration
+	lastDTS  time.Duration
+	lastRecv time.Time
+	jitter   float64 // 秒
+	gaps     int
+	gapMax   time.Duration
+}
+
+// observe 统计一个音频包（recvTime 为包到达时间）
+func (a *audioSampler) observe(dts time.Duration, size int, recvTime time.Time) {
+	a.bytes += int64(size)
+
+	if a.lastRecv.IsZero() {
+		a.firstDTS = dts
+	} else {
+		delta := dts - a.lastDTS
+		if delta > a.gapMax {
+			a.gapMax = delta
+		}
+		if delta > a.gapThreshold {
+			a.gaps++
+		}
+		// RFC 3550 到达间隔抖动：D = 到达间隔 - 时间戳间隔，J += (|D| - J) / 16
+		if !a.segmented {
+			d := recvTime.Sub(a.lastRecv) - delta
+			a.jitter += (math.Abs(d.Seconds()) - a.jitter) / 16
+		}
+	}
+	a.lastDTS = dts
+	a.lastRecv = recvTime
+}
+
+// stats 返回音频统计（info 为解析到的音频参数）
+func (a *audioSampler) stats(info AudioInfo) AudioStats {
+	stats := AudioStats{
+		Info:     info,
+		JitterMs: a.jitter * 1000,
+		Gaps:     a.gaps,
+		GapMaxMs: a.gapMax.Seconds() * 1000,
+	}
+	if span := (a.lastDTS - a.firstDTS).Seconds(); span > 0 {
+		stats.BitrateBps = float64(a.bytes*8) / span
+	}
+	return stats
+}
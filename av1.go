@@ -0,0 +1,293 @@
+package main
+
+import "fmt"
+
+// AV1 OBU 类型
+const av1OBUSequenceHeader = 1
+
+// av1ProfileName AV1 seq_profile 对应的名称
+func av1ProfileName(profile int) string {
+	switch profile {
+	case 0:
+		return "Main"
+	case 1:
+		return "High"
+	case 2:
+		return "Professional"
+	}
+	return fmt.Sprintf("%d", profile)
+}
+
+// av1LevelName AV1 seq_level_idx 对应的名称（例如 8 = 4.0，31 表示无级别限制）
+func av1LevelName(levelIdx int) string {
+	if levelIdx == 31 {
+		return "max"
+	}
+	return fmt.Sprintf("%d.%d", 2+levelIdx>>2, levelIdx&3)
+}
+
+// readLEB128 读取 leb128 编码的无符号数，返回值和占用字节数
+func readLEB128(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8 && i < len(b); i++ {
+		v |= uint64(b[i]&0x7f) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// findAV1SequenceHeader 在 OBU 序列（低开销比特流格式）中查找并解析序列头
+func findAV1SequenceHeader(data []byte) (VideoInfo, bool) {
+	for len(data) > 0 {
+		header := data[0]
+		obuType := int(header>>3) & 0x0f
+		hasExtension := header&0x04 != 0
+		hasSize := header&0x02 != 0
+
+		pos := 1
+		if hasExtension {
+			pos++
+		}
+		size := len(data) - pos
+		if hasSize {
+			v, n := readLEB128(data[min(pos, len(data)):])
+			if n == 0 {
+				return VideoInfo{}, false
+			}
+			pos += n
+			size = int(v)
+		}
+		if size < 0 || pos+size > len(data) {
+			return VideoInfo{}, false
+		}
+
+		if obuType == av1OBUSequenceHeader {
+			info, err := parseAV1SequenceHeader(data[pos : pos+size])
+			return info, err == nil
+		}
+		data = data[pos+size:]
+	}
+	return VideoInfo{}, false
+}
+
+// parseAV1SequenceHeader 解析 AV1 序列头 OBU 的负载（不含 OBU 头）
+// 分辨率取 max_frame_width / max_frame_height，直播流中一般就是实际分辨率
+func parseAV1SequenceHeader(data []byte) (VideoInfo, error) {
+	br := newBitReader(data)
+	u := br.u
+	// uvlc()：前导 0 个数 + 同样位数的值
+	uvlc := func() {
+		leadingZeros := 0
+		for br.err == nil && u(1) == 0 {
+			leadingZeros++
+		}
+		if leadingZeros < 32 {
+			br.skip(leadingZeros)
+		}
+	}
+
+	info := VideoInfo{Codec: "AV1"}
+
+	profile := int(u(3))
+	u(1) // still_picture
+	reducedStillPictureHeader := u(1) != 0
+
+	var levelIdx int
+	if reducedStillPictureHeader {
+		levelIdx = int(u(5))
+	} else {
+		decoderModelInfoPresent := false
+		bufferDelayLength := 0
+		// timing_info_present_flag
+		if u(1) != 0 {
+			br.skip(64) // num_units_in_display_tick / time_scale
+			// equal_picture_interval
+			if u(1) != 0 {
+				uvlc() // num_ticks_per_picture_minus_1
+			}
+			decoderModelInfoPresent = u(1) != 0
+			if decoderModelInfoPresent {
+				bufferDelayLength = int(u(5)) + 1
+				br.skip(32) // num_units_in_decoding_tick
+				br.skip(10) // buffer_removal_time_length_minus_1 / frame_presentation_time_length_minus_1
+			}
+		}
+		initialDisplayDelayPresent := u(1) != 0
+		operatingPoints := int(u(5)) + 1
+		for i := 0; i < operatingPoints && br.err == nil; i++ {
+			u(12) // operating_point_idc
+			idx := int(u(5))
+			if i == 0 {
+				levelIdx = idx
+			}
+			if idx > 7 {
+				u(1) // seq_tier
+			}
+			if decoderModelInfoPresent && u(1) != 0 {
+				// decoder_buffer_delay / encoder_buffer_delay / low_delay_mode_flag
+				br.skip(2*bufferDelayLength + 1)
+			}
+			if initialDisplayDelayPresent && u(1) != 0 {
+				u(4) // initial_display_delay_minus_1
+			}
+		}
+	}
+
+	widthBits := int(u(4)) + 1
+	heightBits := int(u(4)) + 1
+	info.Width = int(u(widthBits)) + 1
+	info.Height = int(u(heightBits)) + 1
+
+	enableOrderHint := false
+	if !reducedStillPictureHeader {
+		// frame_id_numbers_present_flag
+		if u(1) != 0 {
+			u(4) // delta_frame_id_length_minus_2
+			u(3) // additional_frame_id_length_minus_1
+		}
+	}
+	u(1) // use_128x128_superblock
+	u(1) // enable_filter_intra
+	u(1) // enable_intra_edge_filter
+	if !reducedStillPictureHeader {
+		u(1) // enable_interintra_compound
+		u(1) // enable_masked_compound
+		u(1) // enable_warped_motion
+		u(1) // enable_dual_filter
+		enableOrderHint = u(1) != 0
+		if enableOrderHint {
+			u(1) // enable_jnt_comp
+			u(1) // enable_ref_frame_mvs
+		}
+		forceScreenContentTools := uint(2)
+		// seq_choose_screen_content_tools
+		if u(1) == 0 {
+			forceScreenContentTools = u(1)
+		}
+		if forceScreenContentTools > 0 {
+			// seq_choose_integer_mv
+			if u(1) == 0 {
+				u(1) // seq_force_integer_mv
+			}
+		}
+		if enableOrderHint {
+			u(3) // order_hint_bits_minus_1
+		}
+	}
+	u(1) // enable_superres
+	u(1) // enable_cdef
+	u(1) // enable_restoration
+
+	// color_config
+	bitDepth := 8
+	if u(1) != 0 { // high_bitdepth
+		bitDepth = 10
+		if profile == 2 && u(1) != 0 { // twelve_bit
+			bitDepth = 12
+		}
+	}
+	monochrome := false
+	if profile != 1 {
+		monochrome = u(1) != 0
+	}
+	colorPrimaries, transferCharacteristics, matrixCoefficients := uint(2), uint(2), uint(2)
+	// color_description_present_flag
+	if u(1) != 0 {
+		colorPrimaries = u(8)
+		transferCharacteristics = u(8)
+		matrixCoefficients = u(8)
+	}
+	switch {
+	case monochrome:
+		info.ChromaFormat = 0
+	case colorPrimaries == 1 && transferCharacteristics == 13 && matrixCoefficients == 0:
+		// sRGB
+		info.ChromaFormat = 3
+	default:
+		u(1) // color_range
+		switch profile {
+		case 0:
+			info.ChromaFormat = 1
+		case 1:
+			info.ChromaFormat = 3
+		default:
+			subsamplingX, subsamplingY := uint(1), uint(0)
+			if bitDepth == 12 {
+				subsamplingX = u(1)
+				if subsamplingX != 0 {
+					subsamplingY = u(1)
+				}
+			}
+			switch {
+			case subsamplingX != 0 && subsamplingY != 0:
+				info.ChromaFormat = 1
+			case subsamplingX != 0:
+				info.ChromaFormat = 2
+			default:
+				info.ChromaFormat = 3
+			}
+		}
+	}
+	if br.err != nil {
+		return VideoInfo{}, fmt.Errorf("解析 AV1 序列头失败: %w", br.err)
+	}
+
+	info.ProfileIdc = profile
+	info.Profile = av1ProfileName(profile)
+	info.LevelIdc = levelIdx
+	info.Level = av1LevelName(levelIdx)
+	info.BitDepthLuma = bitDepth
+	info.BitDepthChroma = bitDepth
+	if info.Width > 16384 || info.Height > 16384 {
+		return VideoInfo{}, fmt.Errorf("AV1 序列头分辨率无效: %dx%d", info.Width, info.Height)
+	}
+	return info, nil
+}
+
+// parseAV1DecoderConfig 解析 AV1CodecConfigurationRecord（av1C）
+// 优先使用其中 configOBUs 携带的序列头；没有序列头时只能得到 profile / level / 位深 / 色度格式
+func parseAV1DecoderConfig(data []byte) (VideoInfo, error) {
+	if len(data) < 4 || data[0]&0x80 == 0 {
+		return VideoInfo{}, fmt.Errorf("AV1 解码配置无效")
+	}
+	if info, ok := findAV1SequenceHeader(data[4:]); ok {
+		return info, nil
+	}
+
+	profile := int(data[1] >> 5)
+	levelIdx := int(data[1] & 0x1f)
+	highBitdepth := data[2]&0x40 != 0
+	twelveBit := data[2]&0x20 != 0
+	monochrome := data[2]&0x10 != 0
+	subsamplingX := data[2]&0x08 != 0
+	subsamplingY := data[2]&0x04 != 0
+
+	info := VideoInfo{
+		Codec:      "AV1",
+		ProfileIdc: profile,
+		Profile:    av1ProfileName(profile),
+		LevelIdc:   levelIdx,
+		Level:      av1LevelName(levelIdx),
+	}
+	info.BitDepthLuma = 8
+	if highBitdepth {
+		info.BitDepthLuma = 10
+		if twelveBit {
+			info.BitDepthLuma = 12
+		}
+	}
+	info.BitDepthChroma = info.BitDepthLuma
+	switch {
+	case monochrome:
+		info.ChromaFormat = 0
+	case subsamplingX && subsamplingY:
+		info.ChromaFormat = 1
+	case subsamplingX:
+		info.ChromaFormat = 2
+	default:
+		info.ChromaFormat = 3
+	}
+	return info, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// getAVSyncThreshold 获取音画同步阈值（从配置读取，默认200ms）
+func getAVSyncThreshold() time.Duration {
+	if globalConfig != nil && globalConfig.Exporter.AVSyncThresholdMs > 0 {
+		return time.Duration(globalConfig.Exporter.AVSyncThresholdMs) * time.Millisecond
+	}
+	return 200 * time.Millisecond // 默认值
+}
+
+// AVSyncStats 音画同步统计（本次检查的值，没有同时采样到音视频时全部为 0）
+type AVSyncStats struct {
+	OffsetMs      float64 // 平均偏移：音频 DTS - 视频 DTS（正值表示音频时间戳超前于同一时刻的视频）
+	DriftMsPerSec float64 // 偏移随视频 DTS 的变化速率（最小二乘斜率，ms/s），正值表示音频越来越超前
+	Samples       int     // 参与计算的样本数（每个视频包一个）
+}
+
+// avSyncSampler 按流中包的顺序比较音视频 DTS
+// 直播推流端按采集时间交错发送音视频包，每个视频包与它之前最近的音频包的 DTS 差即为该时刻的音画偏移，
+// 精度为一个音频帧（AAC 约 21~23ms）
+type avSyncSampler struct {
+	hasAudio     bool
+	lastAudioDTS time.Duration
+
+	firstVideoDTS time.Duration
+	fit           linearFit // x = 视频 DTS（秒，相对第一个样本），y = 偏移（ms）
+}
+
+// observeAudio 记录一个音频包的 DTS
+func (a *avSyncSampler) observeAudio(dts time.Duration) {
+	a.hasAudio = true
+	a.lastAudioDTS = dts
+}
+
+// observeVideo 记录一个视频包的 DTS，与最近的音频包比较得到一个偏移样本
+func (a *avSyncSampler) observeVideo(dts time.Duration) {
+	if !a.hasAudio {
+		return
+	}
+	if a.fit.n == 0 {
+		a.firstVideoDTS = dts
+	}
+	a.fit.add((dts - a.firstVideoDTS).Seconds(), (a.lastAudioDTS-dts).Seconds()*1000)
+}
+
+// stats 返回平均偏移和漂移速率
+func (a *avSyncSampler) stats() AVSyncStats {
+	return AVSyncStats{
+		OffsetMs:      a.fit.meanY(),
+		DriftMsPerSec: a.fit.slope(),
+		Samples:       a.fit.n,
+	}
+}
+
+// outOfSync 平均偏移是否超过阈值
+func (s AVSyncStats) outOfSync(threshold time.Duration) bool {
+	return s.Samples > 0 && math.Abs(s.OffsetMs) > threshold.Seconds()*1000
+}
+
+// evaluateAVSyncQuality 音画不同步时质量降一级（good -> fair，fair -> poor）
+func evaluateAVSyncQuality(quality string, sync AVSyncStats, threshold time.Duration) string {
+	if !sync.outOfSync(threshold) {
+		return quality
+	}
+	switch quality {
+	case "good":
+		return "fair"
+	case "fair":
+		return "poor"
+	}
+	return quality
+}
@@ -0,0 +1,100 @@
+package main
+
+import "time"
+
+// 初始突发的判定参数
+const (
+	burstMinLead       = 500 * time.Millisecond // 媒体时长比实际耗时多出这么多才算快于实时下发
+	burstLeadTolerance = 100 * time.Millisecond // 领先量达到最大值减去此容差即认为突发结束
+)
+
+// BurstStats 连接建立后的初始突发（GOP 缓存）和突发结束后的稳态速率（本次检查的值）
+type BurstStats struct {
+	Detected            bool    // 是否检测到初始突发（服务端开启了 GOP 缓存）
+	Bytes               int64   // 从连接开始到突发结束读取的字节数
+	DurationMs          float64 // 突发持续的实际时间（第一个视频包到突发结束）
+	MediaMs             float64 // 突发期间下发的媒体时长（按视频 DTS）
+	SteadyThroughputBps float64 // 突发结束后的读取吞吐（没有突发时从第一个视频包开始计算）
+	SteadyBitrateBps    float64 // 突发结束后的码率（读取字节数 / 视频 DTS 跨度）
+}
+
+// burstSample 每个视频包到达时的累计状态
+type burstSample struct {
+	recv      time.Time
+	dts       time.Duration
+	wireBytes int64
+}
+
+// burstTracker 识别连接建立后服务端以快于实时的速度下发的数据
+// 领先量 = DTS 推进 - 实际时间推进；GOP 缓存下发期间领先量快速增长，之后按实时下发时基本不变
+type burstTracker struct {
+	wireBytes *int64 // 网络读取的累计字节数（由 stallTrackingReader 更新）
+	samples   []burstSample
+}
+
+// observe 记录一个视频包
+func (b *burstTracker) observe(dts time.Duration, recvTime time.Time) {
+	var wire int64
+	if b.wireBytes != nil {
+		wire = *b.wireBytes
+	}
+	b.samples = append(b.samples, burstSample{recv: recvTime, dts: dts, wireBytes: wire})
+}
+
+// endIndex 突发结束点在 samples 中的下标，没有突发时为 0
+// 突发结束点取领先量第一次接近最大领先量的视频包（之后的网络抖动只会让领先量在最大值附近波动）
+func (b *burstTracker) endIndex() int {
+	if len(b.samples) < 2 {
+		return 0
+	}
+	first := b.samples[0]
+	lead := func(s burstSample) time.Duration {
+		return (s.dts - first.dts) - s.recv.Sub(first.recv)
+	}
+
+	maxLead := time.Duration(0)
+	for _, s := range b.samples {
+		maxLead = max(maxLead, lead(s))
+	}
+	if maxLead < burstMinLead {
+		return 0
+	}
+	for i, s := range b.samples {
+		if lead(s) >= maxLead-burstLeadTolerance {
+			return i
+		}
+	}
+	return 0
+}
+
+// steady 突发结束后（从结束点开始）的视频包，没有突发时为全部视频包
+func (b *burstTracker) steady() []burstSample {
+	return b.samples[b.endIndex():]
+}
+
+// stats 识别突发结束点并计算稳态速率
+func (b *burstTracker) stats() BurstStats {
+	var stats BurstStats
+	if len(b.samples) < 2 {
+		return stats
+	}
+	first := b.samples[0]
+	i := b.endIndex()
+	end := b.samples[i]
+	if i > 0 {
+		stats.Detected = true
+		stats.Bytes = end.wireBytes
+		stats.DurationMs = end.recv.Sub(first.recv).Seconds() * 1000
+		stats.MediaMs = (end.dts - first.dts).Seconds() * 1000
+	}
+
+	last := b.samples[len(b.samples)-1]
+	steadyBytes := float64(last.wireBytes-end.wireBytes) * 8
+	if elapsed := last.recv.Sub(end.recv).Seconds(); elapsed > 0 {
+		stats.SteadyThroughputBps = steadyBytes / elapsed
+	}
+	if span := (last.dts - end.dts).Seconds(); span > 0 {
+		stats.SteadyBitrateBps = steadyBytes / span
+	}
+	return stats
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// getCaptureDir 获取失败采样的保存目录（从配置读取，默认为空表示不保存）
+func getCaptureDir() string {
+	if globalConfig != nil {
+		return globalConfig.Exporter.CaptureDir
+	}
+	return ""
+}
+
+// getCaptureMaxBytes 获取单次采样保存的最大字节数（从配置读取，默认8MB）
+func getCaptureMaxBytes() int {
+	if globalConfig != nil && globalConfig.Exporter.CaptureMaxSizeMB > 0 {
+		return globalConfig.Exporter.CaptureMaxSizeMB << 20
+	}
+	return 8 << 20 // 默认值
+}
+
+// getCaptureMaxFiles 获取每路流保留的采样文件数（从配置读取，默认5）
+func getCaptureMaxFiles() int {
+	if globalConfig != nil && globalConfig.Exporter.CaptureMaxFiles > 0 {
+		return globalConfig.Exporter.CaptureMaxFiles
+	}
+	return 5 // 默认值
+}
+
+// 保存采样的原因
+const (
+	captureReasonFailed = "failed" // 重试后仍然失败
+	captureReasonPoor   = "poor"   // 检查成功但质量为 poor
+)
+
+// captureExtensions 各协议原始字节对应的文件扩展名
+// HLS 只记录分片（拼接后是合法的 MPEG-TS），RTMP / RTSP 为包含信令的原始字节
+var captureExtensions = map[string]string{
+	"flv":  ".flv",
+	"hls":  ".ts",
+	"rtmp": ".rtmp",
+	"rtsp": ".rtsp",
+}
+
+// captureFileChars 文件名中需要替换的字符
+var captureFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// captureBuffer 记录 stallTrackingReader 读取的原始字节，超过上限后丢弃后面的数据
+// 保留开头部分：FLV 头、onMetaData 和解码配置都在开头，截断后仍然可以回放
+type captureBuffer struct {
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+// Write 追加读取到的数据
+func (c *captureBuffer) Write(p []byte) {
+	if room := c.limit - len(c.data); room < len(p) {
+		p = p[:max(room, 0)]
+		c.truncated = true
+	}
+	c.data = append(c.data, p...)
+}
+
+// captureRecord 与原始字节一起保存的 JSON 说明文件
+type captureRecord struct {
+	Stream     string    `json:"stream"` // project:line:id
+	URL        string    `json:"url"`
+	Protocol   string    `json:"protocol"`
+	RemoteIP   string    `json:"remote_ip,omitempty"` // 实际连接的边缘节点
+	Reason     string    `json:"reason"`              // failed / poor
+	Error      string    `json:"error,omitempty"`
+	StartTime  time.Time `json:"start_time"`
+	DurationMs float64   `json:"duration_ms"`
+
+	// 网络时序（失败时为失败前已经统计到的值）
+	ResponseMs       float64 `json:"response_ms"`
+	TTFBMs           float64 `json:"ttfb_ms"` // 与 video_stream_ttfb_ms 相同，从发起请求开始计时
+	TotalBytes       int64   `json:"total_bytes"`
+	CapturedBytes    int     `json:"captured_bytes"`
+	Truncated        bool    `json:"truncated"` // 超过 capture_max_size_mb，只保存了开头部分
+	ReadStallCount   int64   `json:"read_stall_count"`
+	ReadStallMaxMs   float64 `json:"read_stall_max_ms"`
+	ReadStallTotalMs float64 `json:"read_stall_total_ms"`
+
+	// 检查结果（只有 poor 时有值）
+	Quality      string  `json:"quality,omitempty"`
+	BitrateBps   float64 `json:"bitrate_bps,omitempty"`
+	Framerate    float64 `json:"framerate,omitempty"`
+	Keyframes    int64   `json:"keyframes,omitempty"`
+	VideoPackets int64   `json:"video_packets,omitempty"`
+}
+
+// sampleCapture 一次检查的原始字节和说明（等待决定是否保存）
+type sampleCapture struct {
+	buffer *captureBuffer
+	record captureRecord
+}
+
+// saveCapture 保存最后一次检查的原始字节：err 不为空时按失败保存，否则只在质量为 poor 时保存
+// 由调度器在检查结束后调用（不持有锁写文件）
+func (sc *StreamChecker) saveCapture(err error) {
+	sc.mu.Lock()
+	capture := sc.capture
+	sc.capture = nil
+	if capture != nil {
+		switch {
+		case err != nil:
+			capture.record.Reason = captureReasonFailed
+			capture.record.Error = err.Error()
+		case sc.quality == "poor":
+			capture.record.Reason = captureReasonPoor
+			capture.record.Quality = sc.quality
+			capture.record.BitrateBps = sc.currentBitrate
+			capture.record.Framerate = sc.framerate
+			capture.record.Keyframes = sc.keyframes
+			capture.record.VideoPackets = sc.videoPackets
+		}
+	}
+	sc.mu.Unlock()
+
+	if capture == nil || capture.record.Reason == "" {
+		return
+	}
+	dir := filepath.Join(getCaptureDir(), captureFileChars.ReplaceAllString(sc.Key(), "_"))
+	path, writeErr := writeCapture(dir, capture, getCaptureMaxFiles())
+	if writeErr != nil {
+		sc.log.Warn("保存采样失败", "流ID", sc.id, "目录", dir, "错误", writeErr)
+		return
+	}
+	sc.log.Info("已保存采样", "流ID", sc.id, "原因", capture.record.Reason, "文件", path, "字节", len(capture.buffer.data))
+}
+
+// writeCapture 写入原始字节和 JSON 说明，并删除超过 maxFiles 的旧采样
+func writeCapture(dir string, capture *sampleCapture, maxFiles int) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("创建目录失败: %w", err)
+	}
+	ext, ok := captureExtensions[capture.record.Protocol]
+	if !ok {
+		ext = ".bin"
+	}
+	// 文件名按时间排序：20261016T095812.123Z-failed.flv / .json
+	base := filepath.Join(dir, capture.record.StartTime.UTC().Format("20060102T150405.000Z")+"-"+capture.record.Reason)
+	if err := os.WriteFile(base+ext, capture.buffer.data, 0o644); err != nil {
+		return "", fmt.Errorf("写入采样失败: %w", err)
+	}
+	sidecar, err := json.MarshalIndent(capture.record, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化说明失败: %w", err)
+	}
+	if err := os.WriteFile(base+".json", sidecar, 0o644); err != nil {
+		return "", fmt.Errorf("写入说明失败: %w", err)
+	}
+	return base + ext, rotateCaptures(dir, maxFiles)
+}
+
+// rotateCaptures 每路流只保留最新的 maxFiles 次采样（原始字节和 JSON 说明一起删除）
+func rotateCaptures(dir string, maxFiles int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("读取目录失败: %w", err)
+	}
+	var sidecars []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			sidecars = append(sidecars, entry.Name())
+		}
+	}
+	if len(sidecars) <= maxFiles {
+		return nil
+	}
+	slices.Sort(sidecars)
+	for _, name := range sidecars[:len(sidecars)-maxFiles] {
+		base := strings.TrimSuffix(name, ".json")
+		matches, _ := filepath.Glob(filepath.Join(dir, base+".*"))
+		for _, path := range matches {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("删除旧采样失败: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -9,7 +9,8 @@ import (
 // Config 配置结构
 type Config struct {
 	Exporter ExporterConfig                       `yaml:"exporter"`
-	Streams  map[string]map[string][]StreamConfig `yaml:"streams"` // project -> line -> streams
+	Requests map[string]RequestConfig             `yaml:"requests"` // 默认请求参数：key 为项目（G01）或 项目/线路（G01/CDN）
+	Streams  map[string]map[string][]StreamConfig `yaml:"streams"`  // project -> line -> streams
 	// 第一层 key: 项目/店铺 ID，例如 "G01"
 	// 第二层 key: 线路角色/分组，例如 "SOURCE" / "CDN" / "SERVICE"
 	// 第三层: 流列表
@@ -17,22 +18,56 @@ type Config struct {
 
 // ExporterConfig 导出器配置
 type ExporterConfig struct {
-	CheckInterval    int    `yaml:"check_interval"`  // 检查间隔（秒）
-	SampleDuration   int    `yaml:"sample_duration"` // 采样时长（秒），默认10秒
-	MinKeyframes     int    `yaml:"min_keyframes"`   // 最小关键帧数，默认2
-	MaxConcurrent    int    `yaml:"max_concurrent"`
-	MaxRetries       int    `yaml:"max_retries"`
-	StallThresholdMs int    `yaml:"stall_threshold_ms"` // 读阻塞阈值（毫秒），默认200ms
-	ListenAddr       string `yaml:"listen_addr"`        // Prometheus exporter 监听地址
-	LogLevel         string `yaml:"log_level"`          // 日志级别
+	CheckInterval            int    `yaml:"check_interval"`  // 检查间隔（秒）
+	SampleDuration           int    `yaml:"sample_duration"` // 采样时长（秒），默认10秒
+	MinKeyframes             int    `yaml:"min_keyframes"`   // 最小关键帧数，默认2
+	MaxConcurrent            int    `yaml:"max_concurrent"`
+	MaxRetries               int    `yaml:"max_retries"`
+	StallThresholdMs         int    `yaml:"stall_threshold_ms"`          // 读阻塞阈值（毫秒），默认200ms
+	AudioGapThresholdMs      int    `yaml:"audio_gap_threshold_ms"`      // 音频断流阈值（毫秒），相邻音频包 DTS 间隔超过此值计为断流，默认500ms
+	DTSJumpThresholdMs       int    `yaml:"dts_jump_threshold_ms"`       // 时间戳跳变阈值（毫秒），相邻包 DTS 向前跳变超过此值计为不连续，默认1000ms
+	AVSyncThresholdMs        int    `yaml:"av_sync_threshold_ms"`        // 音画同步阈值（毫秒），平均音画偏移超过此值时质量降一级，默认200ms
+	MaxGOPDurationMs         int    `yaml:"max_gop_duration_ms"`         // GOP 时长上限（毫秒），超过时 video_stream_gop_exceeds_max 为 1，默认0（不检查）
+	MetadataCaptureTimeField string `yaml:"metadata_capture_time_field"` // onMetaData 中携带采集时间的字段名（Unix 毫秒），用于计算端到端延迟，默认不读取
+	MetadataMismatchPercent  int    `yaml:"metadata_mismatch_percent"`   // onMetaData 声明的帧率 / 码率与实测值的偏差阈值（百分比），默认20
+	FrozenMinDurationMs      int    `yaml:"frozen_min_duration_ms"`      // 连续空帧（非关键帧负载极小）超过此时长（毫秒）视为疑似画面静止，默认2000
+	CaptureDir               string `yaml:"capture_dir"`                 // 失败 / 质量为 poor 的采样原始字节保存目录，默认为空（不保存）
+	CaptureMaxSizeMB         int    `yaml:"capture_max_size_mb"`         // 单次采样最多保存的字节数（MB），默认8
+	CaptureMaxFiles          int    `yaml:"capture_max_files"`           // 每路流保留的采样数，默认5
+	FFmpegPath               string `yaml:"ffmpeg_path"`                 // ffmpeg 可执行文件路径（用于解码关键帧和 JPEG 快照），默认在 PATH 中查找 ffmpeg
+	ListenAddr               string `yaml:"listen_addr"`                 // Prometheus exporter 监听地址
+	LogLevel                 string `yaml:"log_level"`                   // 日志级别
 }
 
 // StreamConfig 流配置
 type StreamConfig struct {
-	URL  string            `yaml:"url"`            // 流地址
-	ID   string            `yaml:"id"`             // 流/店铺 ID
-	Tag  string            `yaml:"tag,omitempty"`  // 简单 tag 写法（向后兼容）
-	Tags map[string]string `yaml:"tags,omitempty"` // 自定义标签 map（推荐使用）
+	URL            string            `yaml:"url"`                        // 流地址
+	ID             string            `yaml:"id"`                         // 流/店铺 ID
+	Protocol       string            `yaml:"protocol,omitempty"`         // 拉流协议（flv / hls / rtmp / rtsp），为空时按 URL 自动识别
+	AllowAudioOnly bool              `yaml:"allow_audio_only,omitempty"` // 允许纯音频流（例如电台类频道），没有视频时按音频判定健康和质量
+	AnalyzeFrames  bool              `yaml:"analyze_frames,omitempty"`   // 解码关键帧分析画面内容（黑屏 / 纯色 / 静止），需要 ffmpeg，CPU 开销较大
+	ProbeAllEdges  bool              `yaml:"probe_all_edges,omitempty"`  // 解析域名的全部 A / AAAA 记录，对每个边缘节点分别检查
+	Request        RequestConfig     `yaml:"request,omitempty"`          // 请求头、User-Agent、Referer、Cookie（覆盖项目 / 线路的默认值）
+	Sign           SignConfig        `yaml:"sign,omitempty"`             // CDN 防盗链签名（每次检查前重新生成签名地址）
+	Tag            string            `yaml:"tag,omitempty"`              // 简单 tag 写法（向后兼容）
+	Tags           map[string]string `yaml:"tags,omitempty"`             // 自定义标签 map（推荐使用）
+}
+
+// RequestConfig 拉流请求参数（HTTP-FLV / HLS / RTSP），用于通过 CDN 的 Referer / UA 防盗链
+// 值可以写成 env:变量名 或 file:文件路径，从环境变量或文件读取，避免把密钥写在配置文件中
+type RequestConfig struct {
+	Headers   map[string]string `yaml:"headers,omitempty"`    // 额外请求头
+	UserAgent string            `yaml:"user_agent,omitempty"` // User-Agent
+	Referer   string            `yaml:"referer,omitempty"`    // Referer
+	Cookies   map[string]string `yaml:"cookies,omitempty"`    // Cookie 名 -> 值
+}
+
+// SignConfig CDN 防盗链签名：url 填写不带签名的地址，每次检查前用当前时间重新生成签名地址
+type SignConfig struct {
+	Type string `yaml:"type"`          // 签名方式：tencent / aliyun_a / aliyun_b / aliyun_c / wangsu
+	Key  string `yaml:"key"`           // 签名密钥，支持 env: / file: 形式
+	TTL  int    `yaml:"ttl,omitempty"` // 签名有效期（秒），签名中的时间为当前时间 + ttl，默认1800
+	UID  string `yaml:"uid,omitempty"` // 用户 ID（aliyun_a），默认 0
 }
 
 // LoadConfig 加载配置文件
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// 画面内容分析的判定参数
+const (
+	contentFrameSize      = 64   // 关键帧解码后缩放为 64x64 的灰度图
+	blackLumaThreshold    = 32   // 亮度不超过此值的像素视为黑色（有限范围的黑电平为 16）
+	blackScreenRatio      = 0.98 // 黑色像素占比达到此值视为黑屏
+	blankLumaVariance     = 10   // 亮度方差低于此值视为纯色画面（蓝屏、灰屏、无信号底图）
+	frozenHashMaxDistance = 2    // 与上一次检查关键帧的感知哈希汉明距离不超过此值视为画面静止
+)
+
+// 画面内容问题
+const (
+	contentIssueBlack  = "black"  // 黑屏
+	contentIssueBlank  = "blank"  // 纯色画面
+	contentIssueFrozen = "frozen" // 与上一次检查的关键帧相同
+)
+
+// ContentStats 解码关键帧得到的画面内容分析结果（本次检查的值，未开启或解码失败时为零值）
+type ContentStats struct {
+	Analyzed     bool    // 是否成功解码并分析了关键帧
+	BlackRatio   float64 // 黑色像素占比（0~1）
+	LumaMean     float64 // 平均亮度（0~255）
+	LumaVariance float64 // 亮度方差
+	Similarity   float64 // 与上一次检查关键帧的感知哈希相似度（1 - 汉明距离 / 64），没有上一次的结果时为 0
+	Black        bool    // 黑屏
+	Blank        bool    // 纯色画面
+	Frozen       bool    // 画面静止（与上一次检查的关键帧几乎相同）
+
+	hash uint64 // 本次关键帧的感知哈希，下一次检查用于判断画面是否静止
+}
+
+// analyzeContent 分析灰度图（contentFrameSize x contentFrameSize），prevHash 为上一次检查关键帧的感知哈希
+func analyzeContent(gray []byte, prevHash uint64, hasPrev bool) ContentStats {
+	stats := ContentStats{Analyzed: true, hash: perceptualHash(gray)}
+
+	var sum, sumSq float64
+	black := 0
+	for _, y := range gray {
+		v := float64(y)
+		sum += v
+		sumSq += v * v
+		if y <= blackLumaThreshold {
+			black++
+		}
+	}
+	n := float64(len(gray))
+	stats.LumaMean = sum / n
+	stats.LumaVariance = max(sumSq/n-stats.LumaMean*stats.LumaMean, 0)
+	stats.BlackRatio = float64(black) / n
+
+	stats.Black = stats.BlackRatio >= blackScreenRatio
+	stats.Blank = stats.LumaVariance < blankLumaVariance
+	if hasPrev {
+		distance := bits.OnesCount64(stats.hash ^ prevHash)
+		stats.Similarity = 1 - float64(distance)/64
+		stats.Frozen = distance <= frozenHashMaxDistance
+	}
+	return stats
+}
+
+// perceptualHash 计算感知哈希（pHash）：2x2 平均缩小到 32x32，取 DCT 左上角 8x8 低频系数，
+// 大于中位数的位置 1。画面内容不变时哈希基本不变，编码噪声和码率变化只影响少数几位
+func perceptualHash(gray []byte) uint64 {
+	const n = contentFrameSize / 2
+	var small [n][n]float64
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			i := 2*y*contentFrameSize + 2*x
+			small[y][x] = (float64(gray[i]) + float64(gray[i+1]) +
+				float64(gray[i+contentFrameSize]) + float64(gray[i+contentFrameSize+1])) / 4
+		}
+	}
+
+	var cos [8][n]float64
+	for u := 0; u < 8; u++ {
+		for x := 0; x < n; x++ {
+			cos[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * n))
+		}
+	}
+	coeffs := make([]float64, 0, 64)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					sum += small[y][x] * cos[u][x] * cos[v][y]
+				}
+			}
+			coeffs = append(coeffs, sum)
+		}
+	}
+
+	sorted := slices.Clone(coeffs)
+	slices.Sort(sorted)
+	median := (sorted[31] + sorted[32]) / 2
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// evaluateContentQuality 黑屏、纯色画面或画面静止时质量为 poor
+func evaluateContentQuality(quality string, content ContentStats) string {
+	if content.Black || content.Blank || content.Frozen {
+		return "poor"
+	}
+	return quality
+}
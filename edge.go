@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	urlpkg "net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// edgeResolveTimeout 解析边缘节点地址的超时时间
+const edgeResolveTimeout = 5 * time.Second
+
+// edgeTarget 指定边缘节点：连接 host 时改为连接 ip（端口不变），Host / SNI 仍使用 host
+type edgeTarget struct {
+	host string
+	ip   string
+}
+
+type edgeTargetKey struct{}
+
+// withEdgeTarget 返回指定边缘节点的 context，dialStream 据此改写连接地址
+// 只改写流地址所在的域名，HLS 分片在其他域名上时按正常解析连接
+func withEdgeTarget(ctx context.Context, rawURL, ip string) context.Context {
+	u, err := urlpkg.Parse(rawURL)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, edgeTargetKey{}, edgeTarget{host: u.Hostname(), ip: ip})
+}
+
+// dialStream 建立拉流使用的 TCP 连接，context 中指定了边缘节点时连接该节点的 IP
+func dialStream(ctx context.Context, network, addr string) (net.Conn, error) {
+	if target, ok := ctx.Value(edgeTargetKey{}).(edgeTarget); ok {
+		host, port, err := net.SplitHostPort(addr)
+		if err == nil && strings.EqualFold(host, target.host) {
+			addr = net.JoinHostPort(target.ip, port)
+		}
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// connRemoteIP 返回连接的对端 IP（去掉端口）
+func connRemoteIP(conn net.Conn) string {
+	if conn == nil || conn.RemoteAddr() == nil {
+		return ""
+	}
+	addr := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+var (
+	edgeHTTPClient     *http.Client
+	edgeHTTPClientOnce sync.Once
+)
+
+// httpClientFor 返回拉流请求使用的 HTTP 客户端
+// 指定边缘节点时使用不复用连接的客户端：连接池按域名复用连接，可能拿到其他节点的连接
+func httpClientFor(ctx context.Context) *http.Client {
+	if _, ok := ctx.Value(edgeTargetKey{}).(edgeTarget); !ok {
+		return globalHTTPClient
+	}
+	edgeHTTPClientOnce.Do(func() {
+		edgeHTTPClient = &http.Client{
+			Transport: &http.Transport{
+				DialContext:       dialStream,
+				DisableKeepAlives: true,
+			},
+		}
+	})
+	return edgeHTTPClient
+}
+
+// resolveEdges 解析流地址的域名，返回全部 A / AAAA 记录（去重并排序），地址本身是 IP 时直接返回
+func resolveEdges(ctx context.Context, rawURL string) ([]string, error) {
+	u, err := urlpkg.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("流地址无效: %w", err)
+	}
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("解析域名失败: %w", err)
+	}
+	ips := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP.String())
+	}
+	slices.Sort(ips)
+	return slices.Compact(ips), nil
+}
+
+// newEdgeChecker 创建检查指定边缘节点的子检查器（不解码关键帧，不保存采样）
+func (sc *StreamChecker) newEdgeChecker(ip string) *StreamChecker {
+	return &StreamChecker{
+		id:             sc.id,
+		url:            sc.url,
+		protocol:       sc.protocol,
+		edgeIP:         ip,
+		allowAudioOnly: sc.allowAudioOnly,
+		request:        sc.request,
+		sign:           sc.sign,
+		project:        sc.project,
+		line:           sc.line,
+		labels:         sc.labels,
+		name:           sc.name,
+		quality:        "unknown",
+		bitrateHistory: make([]float64, 0, 10),
+		log:            sc.log.With("边缘节点", ip),
+	}
+}
+
+// syncEdges 按本次解析结果更新子检查器：保留仍在解析结果中的节点，删除已经不在的节点
+func (sc *StreamChecker) syncEdges(ips []string) []*StreamChecker {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	edges := make([]*StreamChecker, 0, len(ips))
+	for _, ip := range ips {
+		i := slices.IndexFunc(sc.edges, func(edge *StreamChecker) bool { return edge.edgeIP == ip })
+		if i >= 0 {
+			edges = append(edges, sc.edges[i])
+		} else {
+			edges = append(edges, sc.newEdgeChecker(ip))
+		}
+	}
+	sc.edges = edges
+	return slices.Clone(edges)
+}
+
+// checkEdges 解析流地址的域名，对每个 A / AAAA 记录分别检查一次（probe_all_edges）
+// 各节点并发检查，每个节点单独占用一个并发名额（semaphore 与主检查共用），失败时与主检查一样重试
+func (s *Scheduler) checkEdges(checker *StreamChecker, semaphore chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), edgeResolveTimeout)
+	ips, err := resolveEdges(ctx, checker.url)
+	cancel()
+	if err != nil {
+		s.log.Warn("解析边缘节点失败", "流ID", checker.id, "错误", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, edge := range checker.syncEdges(ips) {
+		wg.Add(1)
+		go func(edge *StreamChecker) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			s.checkWithRetry(edge)
+		}(edge)
+	}
+	wg.Wait()
+	s.log.Debug("边缘节点检查完成", "流ID", checker.id, "节点数", len(ips))
+}
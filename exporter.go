@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -51,6 +53,21 @@ func getPrometheusLabels(m StreamMetrics) map[string]string {
 	return labels
 }
 
+// getPrometheusLabelValues 按固定顺序（project, line, id, table, desk, biz, isp, role）返回标签值，没有的可选标签为空
+func getPrometheusLabelValues(m StreamMetrics) []string {
+	promLabels := getPrometheusLabels(m)
+	return []string{
+		promLabels["project"],
+		promLabels["line"],
+		promLabels["id"],
+		promLabels["table"], // 可能为空
+		promLabels["desk"],  // 可能为空
+		promLabels["biz"],   // 可能为空
+		promLabels["isp"],   // 可能为空
+		promLabels["role"],  // 可能为空
+	}
+}
+
 // Exporter Prometheus 导出器
 type Exporter struct {
 	streamUp       *prometheus.GaugeVec
@@ -62,6 +79,7 @@ type Exporter struct {
 	keyframes      *prometheus.GaugeVec
 	currentBitrate *prometheus.GaugeVec
 	avgBitrate     *prometheus.GaugeVec
+	videoBitrate   *prometheus.GaugeVec
 	framerate      *prometheus.GaugeVec
 	responseTime   *prometheus.GaugeVec
 	gopSize        *prometheus.GaugeVec
@@ -69,210 +87,1100 @@ type Exporter struct {
 	stabilityScore *prometheus.GaugeVec
 	overallScore   *prometheus.GaugeVec // 综合评分（综合考虑质量和稳定性）
 
-	// 网络指标
-	// 注意：connect_latency_ms 已移除，语义与 response_ms 重复
-	// response_ms: HTTP 响应头返回时间（在 responseTime 指标中）
-	ttfb           *prometheus.GaugeVec
-	readThroughput *prometheus.GaugeVec
-	readStallCount *prometheus.GaugeVec
-	readStallMax   *prometheus.GaugeVec
-	readStallTotal *prometheus.GaugeVec
-	readStallRatio *prometheus.GaugeVec
+	// GOP 时长（按 DTS 统计完整 GOP）
+	gopFramesMin    *prometheus.GaugeVec
+	gopFramesMax    *prometheus.GaugeVec
+	gopDurationMin  *prometheus.GaugeVec
+	gopDurationMax  *prometheus.GaugeVec
+	gopDurationMean *prometheus.GaugeVec
+	gopDurationVar  *prometheus.GaugeVec
+	gopExceedsMax   *prometheus.GaugeVec
+
+	// 视频参数（从 SPS 解析）
+	width        *prometheus.GaugeVec
+	height       *prometheus.GaugeVec
+	profileIdc   *prometheus.GaugeVec
+	levelIdc     *prometheus.GaugeVec
+	chromaFormat *prometheus.GaugeVec
+	bitDepth     *prometheus.GaugeVec
+	videoInfo    *prometheus.GaugeVec
+
+	// 音频指标
+	audioOnly       *prometheus.GaugeVec
+	audioBitrate    *prometheus.GaugeVec
+	audioSampleRate *prometheus.GaugeVec
+	audioChannels   *prometheus.GaugeVec
+	audioJitter     *prometheus.GaugeVec
+	audioGaps       *prometheus.GaugeVec
+	audioGapMax     *prometheus.GaugeVec
+	audioInfo       *prometheus.GaugeVec
+
+	// onMetaData 声明的编码参数
+	metadataInfo         *prometheus.GaugeVec
+	metadataFramerate    *prometheus.GaugeVec
+	metadataVideoBitrate *prometheus.GaugeVec
+	metadataAudioBitrate *prometheus.GaugeVec
+	metadataMismatch     *prometheus.GaugeVec
+
+	// 音画同步
+	avSyncOffset *prometheus.GaugeVec
+	avSyncDrift  *prometheus.GaugeVec
+
+	// 直播延迟
+	clockDrift *prometheus.GaugeVec
+	e2eLatency *prometheus.GaugeVec
+
+	// 画面静止（基于帧大小，不解码）
+	frozenSuspected       *prometheus.GaugeVec
+	frozenTinyRun         *prometheus.GaugeVec
+	interFrameMedianBytes *prometheus.GaugeVec
+	interFrameSizeRatio   *prometheus.GaugeVec
+
+	// 画面内容（解码关键帧，开启 analyze_frames 时）
+	contentAnalyzed     *prometheus.GaugeVec
+	contentBlackRatio   *prometheus.GaugeVec
+	contentLumaMean     *prometheus.GaugeVec
+	contentLumaVariance *prometheus.GaugeVec
+	contentSimilarity   *prometheus.GaugeVec
+	contentIssue        *prometheus.GaugeVec
+
+	// 起播耗时
+	firstVideoPacket *prometheus.GaugeVec
+	firstKeyframe    *prometheus.GaugeVec
+	firstGOP         *prometheus.GaugeVec
+	startupLatency   *prometheus.HistogramVec
+
+	// 帧间隔分布
+	frameDTSInterval     *prometheus.HistogramVec
+	frameArrivalInterval *prometheus.HistogramVec
+
+	// 时间戳连续性（计数器，每次检查累加一次）
+	dtsDiscontinuities *prometheus.CounterVec
+	dtsBackward        *prometheus.CounterVec
+
+	// 网络指标
+	// response_ms: HTTP 响应头返回时间（在 responseTime 指标中），包含 DNS / TCP / TLS / 服务端处理，下面单独拆分
+	dnsLookup        *prometheus.GaugeVec
+	tcpConnect       *prometheus.GaugeVec
+	tlsHandshake     *prometheus.GaugeVec
+	serverProcessing *prometheus.GaugeVec
+	connReused       *prometheus.GaugeVec
+	ttfb             *prometheus.GaugeVec
+	readThroughput   *prometheus.GaugeVec
+	readStallCount   *prometheus.GaugeVec
+	readStallMax     *prometheus.GaugeVec
+	readStallTotal   *prometheus.GaugeVec
+	readStallRatio   *prometheus.GaugeVec
+
+	// 初始突发（GOP 缓存）与稳态速率
+	gopCacheBurst    *prometheus.GaugeVec
+	burstBytes       *prometheus.GaugeVec
+	burstDuration    *prometheus.GaugeVec
+	burstMedia       *prometheus.GaugeVec
+	steadyThroughput *prometheus.GaugeVec
+	steadyBitrate    *prometheus.GaugeVec
+
+	// HLS 指标
+	hlsPlaylistRefresh    *prometheus.GaugeVec
+	hlsSegmentDownload    *prometheus.GaugeVec
+	hlsSegmentDownloadMax *prometheus.GaugeVec
+	hlsSegmentRatio       *prometheus.GaugeVec
+	hlsSegments           *prometheus.GaugeVec
+	hlsSequenceResets     *prometheus.GaugeVec
+	hlsTargetDuration     *prometheus.GaugeVec
+
+	// RTMP 指标
+	rtmpTCPConnect *prometheus.GaugeVec
+	rtmpHandshake  *prometheus.GaugeVec
+	rtmpConnect    *prometheus.GaugeVec
+	rtmpPlay       *prometheus.GaugeVec
+
+	// RTSP 指标
+	rtpPackets     *prometheus.GaugeVec
+	rtpLostPackets *prometheus.GaugeVec
+	rtpSeqGaps     *prometheus.GaugeVec
+	rtpLossRatio   *prometheus.GaugeVec
+	rtpReordered   *prometheus.GaugeVec
+	rtspInfo       *prometheus.GaugeVec
+
+	// TLS 证书和握手（仅 https:// / rtmps://，每次更新前清空）
+	tlsInfo          *prometheus.GaugeVec
+	tlsCertNotAfter  *prometheus.GaugeVec
+	tlsCertVerified  *prometheus.GaugeVec
+	tlsHostnameMatch *prometheus.GaugeVec
+	tlsOCSPStapled   *prometheus.GaugeVec
+
+	// 边缘节点：实际连接的 IP，以及 probe_all_edges 的逐节点结果（edge_ip 标签）
+	remoteInfo         *prometheus.GaugeVec
+	edgeUp             *prometheus.GaugeVec
+	edgeResponse       *prometheus.GaugeVec
+	edgeTCPConnect     *prometheus.GaugeVec
+	edgeTTFB           *prometheus.GaugeVec
+	edgeReadThroughput *prometheus.GaugeVec
+	edgeReadStallRatio *prometheus.GaugeVec
+	edgeBitrate        *prometheus.GaugeVec
+	edgeFramerate      *prometheus.GaugeVec
+
+	// 抓取互斥：updateMetrics 会清空信息类、TLS 和边缘节点指标后重新设置，
+	// 并发的抓取需要串行执行，否则一个抓取可能在另一个抓取输出期间清空序列
+	scrapeMu sync.Mutex
+
+	scheduler *Scheduler
+	log       *slog.Logger
+}
+
+// frameIntervalBuckets 帧间隔直方图的桶（毫秒）：覆盖成批到达（接近 0）、常见帧率（60/30/25fps）和明显卡顿
+var frameIntervalBuckets = []float64{1, 5, 10, 17, 20, 34, 40, 50, 67, 100, 200, 500, 1000}
+
+// NewExporter 创建导出器
+// 注意：由于 Prometheus 标签必须固定，我们使用基础标签（project, line, id）+ 可选标签（table, desk, biz, isp, role）
+// 如果某个流没有某个可选标签，则使用空字符串
+// 语义说明：
+//   - project: 项目/店铺 ID
+//   - line: 线路角色（SOURCE/SERVICE/CDN，拓扑节点）
+//   - id: 流/店铺 ID
+//   - table: 店铺编号（可选）
+//   - desk: 柜台编号（可选）
+//   - biz: 商品类别（可选）
+//   - isp: 运营商（可选）
+//   - role: 角色/用途标识（可选）
+func NewExporter(scheduler *Scheduler) *Exporter {
+	// 定义标签列表：基础标签 + 可选标签
+	labelNames := []string{"project", "line", "id", "table", "desk", "biz", "isp", "role"}
+
+	exporter := &Exporter{
+		scheduler: scheduler,
+		log:       GetLogger(),
+
+		streamUp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_up",
+				Help: "Stream is up (1) or down (0)",
+			},
+			labelNames,
+		),
+
+		streamHealthy: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_healthy",
+				Help: "Stream health status (1=healthy, 0=unhealthy)",
+			},
+			labelNames,
+		),
+
+		streamPlayable: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_playable",
+				Help: "Stream is playable (1=yes, 0=no)",
+			},
+			labelNames,
+		),
+
+		totalPackets: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_total_packets",
+				Help: "Total packets received",
+			},
+			labelNames,
+		),
+
+		videoPackets: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_video_packets",
+				Help: "Video packets received",
+			},
+			labelNames,
+		),
+
+		audioPackets: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_audio_packets",
+				Help: "Audio packets received",
+			},
+			labelNames,
+		),
+
+		keyframes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_keyframes",
+				Help: "Keyframes received",
+			},
+			labelNames,
+		),
+
+		currentBitrate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_bitrate_bps",
+				Help: "Current stream bitrate in bits per second",
+			},
+			labelNames,
+		),
+
+		avgBitrate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_avg_bitrate_bps",
+				Help: "Average stream bitrate in bits per second",
+			},
+			labelNames,
+		),
+
+		videoBitrate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_video_bitrate_bps",
+				Help: "Video bitrate in bits per second computed from video frame payload over the video DTS span (excludes container overhead and audio)",
+			},
+			labelNames,
+		),
+
+		framerate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_framerate",
+				Help: "Stream framerate in fps",
+			},
+			labelNames,
+		),
+
+		responseTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_response_ms",
+				Help: "Stream request response time in milliseconds (FLV response header, HLS playlist response header, RTMP play onStatus or RTSP PLAY response)",
+			},
+			labelNames,
+		),
+
+		gopSize: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_gop_size",
+				Help: "GOP size in frames (mean of complete GOPs in the sample window)",
+			},
+			labelNames,
+		),
+
+		// GOP 时长（只统计采样窗口内相邻两个关键帧之间的完整 GOP）
+		gopFramesMin: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_gop_frames_min",
+				Help: "Minimum number of frames of complete GOPs in the sample window",
+			},
+			labelNames,
+		),
+
+		gopFramesMax: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_gop_frames_max",
+				Help: "Maximum number of frames of complete GOPs in the sample window",
+			},
+			labelNames,
+		),
+
+		gopDurationMin: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_gop_duration_min_seconds",
+				Help: "Minimum keyframe-to-keyframe DTS interval in seconds",
+			},
+			labelNames,
+		),
+
+		gopDurationMax: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_gop_duration_max_seconds",
+				Help: "Maximum keyframe-to-keyframe DTS interval in seconds",
+			},
+			labelNames,
+		),
+
+		gopDurationMean: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_gop_duration_mean_seconds",
+				Help: "Mean keyframe-to-keyframe DTS interval in seconds",
+			},
+			labelNames,
+		),
+
+		gopDurationVar: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_gop_duration_variance",
+				Help: "Variance of keyframe-to-keyframe DTS intervals in seconds squared",
+			},
+			labelNames,
+		),
+
+		gopExceedsMax: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_gop_exceeds_max",
+				Help: "GOP duration exceeds max_gop_duration_ms (1) or not (0), always 0 when the limit is not configured",
+			},
+			labelNames,
+		),
+
+		// 视频参数（从 SPS 解析，未解析到时为 0）
+		width: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_width",
+				Help: "Video width in pixels parsed from SPS",
+			},
+			labelNames,
+		),
+
+		height: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_height",
+				Help: "Video height in pixels parsed from SPS",
+			},
+			labelNames,
+		),
+
+		profileIdc: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_profile_idc",
+				Help: "Video profile_idc parsed from SPS (e.g. 66=Baseline, 77=Main, 100=High)",
+			},
+			labelNames,
+		),
+
+		levelIdc: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_level_idc",
+				Help: "Video level_idc parsed from SPS (e.g. 31=3.1, 40=4.0)",
+			},
+			labelNames,
+		),
+
+		chromaFormat: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_chroma_format_idc",
+				Help: "Video chroma_format_idc parsed from SPS (0=4:0:0, 1=4:2:0, 2=4:2:2, 3=4:4:4)",
+			},
+			labelNames,
+		),
+
+		bitDepth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_bit_depth",
+				Help: "Video luma bit depth parsed from SPS",
+			},
+			labelNames,
+		),
+
+		// 信息类指标：值恒为 1，视频参数放在标签中
+		videoInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_info",
+				Help: "Video codec parameters parsed from SPS (value is always 1)",
+			},
+			append(append([]string{}, labelNames...), "video_codec", "profile", "level", "resolution", "chroma_format", "bit_depth"),
+		),
+
+		// 音频指标（没有音频的流为 0）
+		audioOnly: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_audio_only",
+				Help: "Stream has audio but no video and is accepted as audio-only (1=yes, 0=no)",
+			},
+			labelNames,
+		),
+
+		audioBitrate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_audio_bitrate_bps",
+				Help: "Audio bitrate in bits per second computed from audio frame payload over the audio DTS span",
+			},
+			labelNames,
+		),
+
+		audioSampleRate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_audio_sample_rate_hz",
+				Help: "Audio sample rate in Hz parsed from the AAC AudioSpecificConfig",
+			},
+			labelNames,
+		),
+
+		audioChannels: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_audio_channels",
+				Help: "Number of audio channels parsed from the AAC AudioSpecificConfig",
+			},
+			labelNames,
+		),
+
+		audioJitter: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_audio_jitter_ms",
+				Help: "Audio packet interarrival jitter in milliseconds (RFC 3550 estimator, arrival interval vs DTS interval); 0 for HLS, whose segments arrive in one piece",
+			},
+			labelNames,
+		),
+
+		audioGaps: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_audio_gaps",
+				Help: "Number of gaps between consecutive audio packets whose DTS delta exceeds audio_gap_threshold_ms in the last check",
+			},
+			labelNames,
+		),
+
+		audioGapMax: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_audio_gap_max_ms",
+				Help: "Largest DTS delta between consecutive audio packets in milliseconds in the last check",
+			},
+			labelNames,
+		),
+
+		// 信息类指标：值恒为 1，音频参数放在标签中
+		audioInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_audio_info",
+				Help: "Audio codec parameters parsed from AudioSpecificConfig (value is always 1)",
+			},
+			append(append([]string{}, labelNames...), "audio_codec", "audio_profile", "sample_rate", "channels"),
+		),
+
+		// onMetaData 声明的编码参数（FLV / RTMP，没有 onMetaData 时为 0）
+		// 信息类指标：值恒为 1，编码器和声明值放在标签中
+		metadataInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_metadata_info",
+				Help: "Encoder parameters declared in FLV onMetaData (value is always 1)",
+			},
+			append(append([]string{}, labelNames...), "encoder", "width", "height", "framerate", "videodatarate", "audiodatarate"),
+		),
+
+		metadataFramerate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_metadata_framerate",
+				Help: "Framerate declared in onMetaData",
+			},
+			labelNames,
+		),
+
+		metadataVideoBitrate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_metadata_video_bitrate_bps",
+				Help: "Video bitrate declared in onMetaData (videodatarate) in bits per second",
+			},
+			labelNames,
+		),
+
+		metadataAudioBitrate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_metadata_audio_bitrate_bps",
+				Help: "Audio bitrate declared in onMetaData (audiodatarate) in bits per second",
+			},
+			labelNames,
+		),
+
+		metadataMismatch: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_metadata_mismatch",
+				Help: "Measured value diverges from the onMetaData declared value by more than metadata_mismatch_percent (1) or not (0), by field (framerate, video_bitrate, audio_bitrate)",
+			},
+			append(append([]string{}, labelNames...), "field"),
+		),
+
+		// 音画同步
+		avSyncOffset: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_av_sync_offset_ms",
+				Help: "Average audio/video timestamp offset in milliseconds (audio DTS minus video DTS at the same point of the stream, positive means audio ahead)",
+			},
+			labelNames,
+		),
+
+		avSyncDrift: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_av_sync_drift_ms_per_second",
+				Help: "Change rate of the audio/video offset within the sample window in milliseconds per second of video",
+			},
+			labelNames,
+		),
+
+		// 直播延迟
+		clockDrift: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_clock_drift_ms_per_second",
+				Help: "Growth rate of stream lag behind wall clock in milliseconds per second (positive means DTS advances slower than real time), fitted after the initial GOP-cache burst; 0 for HLS, whose segments arrive in one piece",
+			},
+			labelNames,
+		),
+
+		e2eLatency: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_e2e_latency_ms",
+				Help: "End-to-end latency from capture time (SEI or onMetaData) to packet arrival in milliseconds, 0 when no capture time is present",
+			},
+			labelNames,
+		),
+
+		// 画面静止（基于帧大小，不解码）
+		frozenSuspected: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_frozen_suspected",
+				Help: "Frozen picture suspected from frame sizes (1=yes, 0=no): sustained run of near-empty non-key frames or collapse of non-key frame size against history",
+			},
+			labelNames,
+		),
+
+		frozenTinyRun: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_frozen_tiny_run_seconds",
+				Help: "Longest run of near-empty non-key frames by DTS in seconds",
+			},
+			labelNames,
+		),
+
+		interFrameMedianBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_inter_frame_median_bytes",
+				Help: "Median payload size of non-key video frames in bytes",
+			},
+			labelNames,
+		),
+
+		interFrameSizeRatio: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_inter_frame_size_ratio",
+				Help: "Median non-key frame size relative to the stream's recent history (0 when history is insufficient)",
+			},
+			labelNames,
+		),
+
+		// 画面内容（解码关键帧，开启 analyze_frames 时）
+		contentAnalyzed: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_content_analyzed",
+				Help: "Last sampled keyframe was decoded and analyzed (1=yes, 0=no or analyze_frames disabled)",
+			},
+			labelNames,
+		),
+
+		contentBlackRatio: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_content_black_ratio",
+				Help: "Ratio of black pixels (luma <= 32) in the decoded keyframe (0~1)",
+			},
+			labelNames,
+		),
+
+		contentLumaMean: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_content_luma_mean",
+				Help: "Mean luma of the decoded keyframe (0~255)",
+			},
+			labelNames,
+		),
+
+		contentLumaVariance: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_content_luma_variance",
+				Help: "Luma variance of the decoded keyframe (near 0 for blank single-color pictures)",
+			},
+			labelNames,
+		),
+
+		contentSimilarity: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_content_keyframe_similarity",
+				Help: "Perceptual hash similarity between the keyframes of this check and the previous check (0~1, 0 when no previous keyframe)",
+			},
+			labelNames,
+		),
+
+		contentIssue: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_content_issue",
+				Help: "Content issue detected in the decoded keyframe (1=yes, 0=no), by issue (black, blank, frozen)",
+			},
+			append(append([]string{}, labelNames...), "issue"),
+		),
+
+		qualityScore: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_quality_score",
+				Help: "Stream quality score (0=poor, 1=fair, 2=good)",
+			},
+			labelNames,
+		),
+
+		stabilityScore: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_stability_score",
+				Help: "Bitrate stability score (0=unstable, 1=moderate, 2=stable)",
+			},
+			labelNames,
+		),
+
+		overallScore: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_overall_score",
+				Help: "Overall quality score considering both video quality and network stability (0=poor, 1=good/fair, 2=excellent)",
+			},
+			labelNames,
+		),
+
+		// 起播耗时（从发起请求开始计时）
+		firstVideoPacket: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_first_video_packet_ms",
+				Help: "Time from request start to the first video packet in milliseconds",
+			},
+			labelNames,
+		),
+
+		firstKeyframe: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_first_keyframe_ms",
+				Help: "Time from request start to the first keyframe (first decodable picture) in milliseconds",
+			},
+			labelNames,
+		),
+
+		firstGOP: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_first_gop_ms",
+				Help: "Time from request start to the first complete GOP (second keyframe) in milliseconds",
+			},
+			labelNames,
+		),
+
+		startupLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "video_stream_startup_latency_ms",
+				Help:    "Distribution of startup latency in milliseconds by stage (first_video_packet, first_keyframe, first_gop), observed once per check",
+				Buckets: []float64{100, 250, 500, 1000, 2000, 3000, 5000, 10000, 20000},
+			},
+			append(append([]string{}, labelNames...), "stage"),
+		),
+
+		// 帧间隔分布：DTS 间隔（编码端）与到达间隔（网络 / 服务端）
+		frameDTSInterval: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "video_stream_frame_dts_interval_ms",
+				Help:    "Distribution of DTS intervals between consecutive video packets in milliseconds, observed once per check; not observed for RTSP, which only has PTS",
+				Buckets: frameIntervalBuckets,
+			},
+			labelNames,
+		),
+
+		frameArrivalInterval: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "video_stream_frame_arrival_interval_ms",
+				Help:    "Distribution of wall-clock arrival intervals between consecutive video packets in milliseconds, observed once per check",
+				Buckets: frameIntervalBuckets,
+			},
+			labelNames,
+		),
+
+		// 时间戳连续性（track: video / audio）
+		dtsDiscontinuities: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "video_stream_dts_discontinuities_total",
+				Help: "Total number of forward DTS jumps larger than dts_jump_threshold_ms between consecutive packets, by track (video, audio)",
+			},
+			append(append([]string{}, labelNames...), "track"),
+		),
+
+		dtsBackward: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "video_stream_dts_backward_total",
+				Help: "Total number of backward (non-monotonic) DTS steps between consecutive packets, by track (video, audio); RTSP video only has PTS, so only steps back by more than dts_jump_threshold_ms are counted",
+			},
+			append(append([]string{}, labelNames...), "track"),
+		),
+
+		// 网络指标
+		// response_ms: HTTP 响应头返回时间（在 responseTime 指标中），包含 DNS / TCP / TLS / 服务端处理，下面单独拆分
+		dnsLookup: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_dns_lookup_ms",
+				Help: "DNS lookup time of the HTTP request in milliseconds (HTTP-FLV / HLS first playlist request, 0 when the connection was reused)",
+			},
+			labelNames,
+		),
+
+		tcpConnect: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_tcp_connect_ms",
+				Help: "TCP connect time in milliseconds (HTTP-FLV / HLS / RTMP, 0 when the connection was reused)",
+			},
+			labelNames,
+		),
+
+		tlsHandshake: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_tls_handshake_ms",
+				Help: "TLS handshake time of the HTTP request in milliseconds (0 for http:// or when the connection was reused)",
+			},
+			labelNames,
+		),
+
+		serverProcessing: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_server_processing_ms",
+				Help: "Time from request written to first response byte in milliseconds (server think time plus one round trip)",
+			},
+			labelNames,
+		),
+
+		connReused: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_connection_reused",
+				Help: "HTTP request reused a pooled keep-alive connection (1) or dialed a new one (0)",
+			},
+			labelNames,
+		),
+
+		ttfb: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_ttfb_ms",
+				Help: "Time to first byte (TTFB) in milliseconds",
+			},
+			labelNames,
+		),
+
+		readThroughput: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_read_throughput_bps",
+				Help: "Average read throughput during sampling period in bits per second",
+			},
+			labelNames,
+		),
+
+		readStallCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_read_stall_count",
+				Help: "Number of read stalls (reads taking longer than 200ms)",
+			},
+			labelNames,
+		),
 
-	scheduler *Scheduler
-	log       *slog.Logger
-}
+		readStallMax: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_read_stall_max_ms",
+				Help: "Maximum read stall duration in milliseconds",
+			},
+			labelNames,
+		),
 
-// NewExporter 创建导出器
-// 注意：由于 Prometheus 标签必须固定，我们使用基础标签（project, line, id）+ 可选标签（table, desk, biz, isp, role）
-// 如果某个流没有某个可选标签，则使用空字符串
-// 语义说明：
-//   - project: 项目/店铺 ID
-//   - line: 线路角色（SOURCE/SERVICE/CDN，拓扑节点）
-//   - id: 流/店铺 ID
-//   - table: 店铺编号（可选）
-//   - desk: 柜台编号（可选）
-//   - biz: 商品类别（可选）
-//   - isp: 运营商（可选）
-//   - role: 角色/用途标识（可选）
-func NewExporter(scheduler *Scheduler) *Exporter {
-	// 定义标签列表：基础标签 + 可选标签
-	labelNames := []string{"project", "line", "id", "table", "desk", "biz", "isp", "role"}
+		readStallTotal: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_read_stall_total_ms",
+				Help: "Total read stall duration in milliseconds",
+			},
+			labelNames,
+		),
 
-	exporter := &Exporter{
-		scheduler: scheduler,
-		log:       GetLogger(),
+		readStallRatio: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_read_stall_ratio",
+				Help: "Ratio of read stall time to total sampling duration (0~1). Higher values indicate more network jitter",
+			},
+			labelNames,
+		),
 
-		streamUp: prometheus.NewGaugeVec(
+		// 初始突发（GOP 缓存）与稳态速率
+		gopCacheBurst: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_up",
-				Help: "Stream is up (1) or down (0)",
+				Name: "video_stream_gop_cache_burst",
+				Help: "Initial burst delivered faster than real time after connect (1) or not (0), indicating a GOP cache on the server",
 			},
 			labelNames,
 		),
 
-		streamHealthy: prometheus.NewGaugeVec(
+		burstBytes: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_healthy",
-				Help: "Stream health status (1=healthy, 0=unhealthy)",
+				Name: "video_stream_burst_bytes",
+				Help: "Bytes read from connect until the end of the initial burst",
 			},
 			labelNames,
 		),
 
-		streamPlayable: prometheus.NewGaugeVec(
+		burstDuration: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_playable",
-				Help: "Stream is playable (1=yes, 0=no)",
+				Name: "video_stream_burst_duration_ms",
+				Help: "Wall-clock duration of the initial burst in milliseconds",
 			},
 			labelNames,
 		),
 
-		totalPackets: prometheus.NewGaugeVec(
+		burstMedia: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_total_packets",
-				Help: "Total packets received",
+				Name: "video_stream_burst_media_ms",
+				Help: "Media duration (video DTS) delivered during the initial burst in milliseconds",
 			},
 			labelNames,
 		),
 
-		videoPackets: prometheus.NewGaugeVec(
+		steadyThroughput: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_video_packets",
-				Help: "Video packets received",
+				Name: "video_stream_steady_throughput_bps",
+				Help: "Read throughput in bits per second after the initial burst ends",
 			},
 			labelNames,
 		),
 
-		audioPackets: prometheus.NewGaugeVec(
+		steadyBitrate: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_audio_packets",
-				Help: "Audio packets received",
+				Name: "video_stream_steady_bitrate_bps",
+				Help: "Stream bitrate in bits per second after the initial burst ends (bytes read / video DTS span)",
 			},
 			labelNames,
 		),
 
-		keyframes: prometheus.NewGaugeVec(
+		// HLS 指标（非 HLS 流为 0）
+		hlsPlaylistRefresh: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_keyframes",
-				Help: "Keyframes received",
+				Name: "video_stream_hls_playlist_refresh_ms",
+				Help: "Average HLS media playlist refresh latency in milliseconds",
 			},
 			labelNames,
 		),
 
-		currentBitrate: prometheus.NewGaugeVec(
+		hlsSegmentDownload: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_bitrate_bps",
-				Help: "Current stream bitrate in bits per second",
+				Name: "video_stream_hls_segment_download_ms",
+				Help: "Average HLS segment download time in milliseconds",
 			},
 			labelNames,
 		),
 
-		avgBitrate: prometheus.NewGaugeVec(
+		hlsSegmentDownloadMax: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_avg_bitrate_bps",
-				Help: "Average stream bitrate in bits per second",
+				Name: "video_stream_hls_segment_download_max_ms",
+				Help: "Maximum HLS segment download time in milliseconds",
 			},
 			labelNames,
 		),
 
-		framerate: prometheus.NewGaugeVec(
+		hlsSegmentRatio: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_framerate",
-				Help: "Stream framerate in fps",
+				Name: "video_stream_hls_segment_download_ratio",
+				Help: "HLS segment download time divided by segment duration. Values above 1 mean downloads cannot keep up with playback",
 			},
 			labelNames,
 		),
 
-		responseTime: prometheus.NewGaugeVec(
+		hlsSegments: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_response_ms",
-				Help: "FLV HTTP request response time in milliseconds",
+				Name: "video_stream_hls_segments",
+				Help: "Number of HLS segments downloaded during the check",
 			},
 			labelNames,
 		),
 
-		gopSize: prometheus.NewGaugeVec(
+		hlsSequenceResets: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_gop_size",
-				Help: "GOP size in frames",
+				Name: "video_stream_hls_media_sequence_resets",
+				Help: "Number of times the HLS media sequence went backwards during the check (packager or encoder restart)",
 			},
 			labelNames,
 		),
 
-		qualityScore: prometheus.NewGaugeVec(
+		hlsTargetDuration: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_quality_score",
-				Help: "Stream quality score (0=poor, 1=fair, 2=good)",
+				Name: "video_stream_hls_target_duration_seconds",
+				Help: "HLS EXT-X-TARGETDURATION of the media playlist in seconds",
 			},
 			labelNames,
 		),
 
-		stabilityScore: prometheus.NewGaugeVec(
+		// RTMP 指标（非 RTMP 流为 0）
+		rtmpTCPConnect: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_stability_score",
-				Help: "Bitrate stability score (0=unstable, 1=moderate, 2=stable)",
+				Name: "video_stream_rtmp_tcp_connect_ms",
+				Help: "RTMP TCP connect time in milliseconds (including TLS handshake for rtmps)",
 			},
 			labelNames,
 		),
 
-		overallScore: prometheus.NewGaugeVec(
+		rtmpHandshake: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_overall_score",
-				Help: "Overall quality score considering both video quality and network stability (0=poor, 1=good/fair, 2=excellent)",
+				Name: "video_stream_rtmp_handshake_ms",
+				Help: "RTMP handshake (C0/C1/C2 - S0/S1/S2) time in milliseconds",
 			},
 			labelNames,
 		),
 
-		// 网络指标
-		// 注意：connect_latency_ms 已移除，语义与 response_ms 重复
-		// response_ms: HTTP 响应头返回时间（在 responseTime 指标中）
-		ttfb: prometheus.NewGaugeVec(
+		rtmpConnect: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_ttfb_ms",
-				Help: "Time to first byte (TTFB) in milliseconds",
+				Name: "video_stream_rtmp_connect_ms",
+				Help: "RTMP connect command round trip time in milliseconds",
 			},
 			labelNames,
 		),
 
-		readThroughput: prometheus.NewGaugeVec(
+		rtmpPlay: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_read_throughput_bps",
-				Help: "Average read throughput during sampling period in bits per second",
+				Name: "video_stream_rtmp_play_ms",
+				Help: "RTMP createStream and play command time until onStatus in milliseconds",
 			},
 			labelNames,
 		),
 
-		readStallCount: prometheus.NewGaugeVec(
+		// RTSP 指标（非 RTSP 流为 0）
+		rtpPackets: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_read_stall_count",
-				Help: "Number of read stalls (reads taking longer than 200ms)",
+				Name: "video_stream_rtp_packets",
+				Help: "Number of RTP packets received in the last RTSP check",
 			},
 			labelNames,
 		),
 
-		readStallMax: prometheus.NewGaugeVec(
+		rtpLostPackets: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_read_stall_max_ms",
-				Help: "Maximum read stall duration in milliseconds",
+				Name: "video_stream_rtp_lost_packets",
+				Help: "Number of RTP packets lost (by sequence number gaps) in the last RTSP check",
 			},
 			labelNames,
 		),
 
-		readStallTotal: prometheus.NewGaugeVec(
+		rtpSeqGaps: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_read_stall_total_ms",
-				Help: "Total read stall duration in milliseconds",
+				Name: "video_stream_rtp_seq_gaps",
+				Help: "Number of RTP sequence number gaps in the last RTSP check, including resyncs after an SSRC change or a large sequence jump",
 			},
 			labelNames,
 		),
 
-		readStallRatio: prometheus.NewGaugeVec(
+		rtpLossRatio: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "video_stream_read_stall_ratio",
-				Help: "Ratio of read stall time to total sampling duration (0~1). Higher values indicate more network jitter",
+				Name: "video_stream_rtp_loss_ratio",
+				Help: "RTP packet loss ratio (lost / expected, 0-1) in the last RTSP check",
+			},
+			labelNames,
+		),
+
+		rtpReordered: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_rtp_reordered_packets",
+				Help: "Number of out-of-order or duplicate RTP packets in the last RTSP check",
+			},
+			labelNames,
+		),
+
+		// 信息类指标：值恒为 1，编码信息放在标签中
+		rtspInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_rtsp_info",
+				Help: "Codec info declared in the RTSP SDP (value is always 1)",
+			},
+			append(append([]string{}, labelNames...), "video_codec", "video_profile", "audio_codec", "audio_sample_rate", "audio_channels"),
+		),
+
+		// TLS 证书和握手
+		tlsInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_tls_info",
+				Help: "Negotiated TLS version, cipher suite and leaf certificate issuer of https:// / rtmps:// streams (value is always 1)",
+			},
+			append(append([]string{}, labelNames...), "version", "cipher", "issuer"),
+		),
+
+		tlsCertNotAfter: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_tls_cert_not_after_timestamp_seconds",
+				Help: "Expiry (NotAfter) of the peer leaf certificate as a Unix timestamp, also reported when verification failed",
+			},
+			labelNames,
+		),
+
+		tlsCertVerified: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_tls_cert_verified",
+				Help: "Peer certificate chain and hostname verified (1) or verification failed (0)",
+			},
+			labelNames,
+		),
+
+		tlsHostnameMatch: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_tls_cert_hostname_match",
+				Help: "Peer leaf certificate SAN matches the stream URL host (1) or not (0)",
+			},
+			labelNames,
+		),
+
+		tlsOCSPStapled: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_tls_ocsp_stapled",
+				Help: "Server stapled an OCSP response in the TLS handshake (1) or not (0)",
 			},
 			labelNames,
 		),
+
+		// 边缘节点
+		remoteInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_remote_info",
+				Help: "Remote IP address (edge node) the last check connected to, kept after a failed check (value is always 1)",
+			},
+			append(append([]string{}, labelNames...), "ip"),
+		),
+
+		edgeUp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_edge_up",
+				Help: "Stream is up (1) or down (0) when pulled from this edge IP (probe_all_edges)",
+			},
+			append(append([]string{}, labelNames...), "edge_ip"),
+		),
+
+		edgeResponse: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_edge_response_ms",
+				Help: "Response time in milliseconds when pulled from this edge IP (probe_all_edges)",
+			},
+			append(append([]string{}, labelNames...), "edge_ip"),
+		),
+
+		edgeTCPConnect: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_edge_tcp_connect_ms",
+				Help: "TCP connect time in milliseconds to this edge IP (probe_all_edges)",
+			},
+			append(append([]string{}, labelNames...), "edge_ip"),
+		),
+
+		edgeTTFB: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_edge_ttfb_ms",
+				Help: "Time to first byte in milliseconds when pulled from this edge IP (probe_all_edges)",
+			},
+			append(append([]string{}, labelNames...), "edge_ip"),
+		),
+
+		edgeReadThroughput: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_edge_read_throughput_bps",
+				Help: "Average read throughput in bits per second when pulled from this edge IP (probe_all_edges)",
+			},
+			append(append([]string{}, labelNames...), "edge_ip"),
+		),
+
+		edgeReadStallRatio: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_edge_read_stall_ratio",
+				Help: "Ratio of read stall time to sampling duration (0~1) when pulled from this edge IP (probe_all_edges)",
+			},
+			append(append([]string{}, labelNames...), "edge_ip"),
+		),
+
+		edgeBitrate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_edge_bitrate_bps",
+				Help: "Current bitrate in bits per second when pulled from this edge IP (probe_all_edges)",
+			},
+			append(append([]string{}, labelNames...), "edge_ip"),
+		),
+
+		edgeFramerate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "video_stream_edge_framerate",
+				Help: "Video framerate in fps when pulled from this edge IP (probe_all_edges)",
+			},
+			append(append([]string{}, labelNames...), "edge_ip"),
+		),
 	}
 
 	// 注册指标
@@ -286,44 +1194,135 @@ func NewExporter(scheduler *Scheduler) *Exporter {
 		exporter.keyframes,
 		exporter.currentBitrate,
 		exporter.avgBitrate,
+		exporter.videoBitrate,
 		exporter.framerate,
 		exporter.responseTime,
 		exporter.gopSize,
+		exporter.gopFramesMin,
+		exporter.gopFramesMax,
+		exporter.gopDurationMin,
+		exporter.gopDurationMax,
+		exporter.gopDurationMean,
+		exporter.gopDurationVar,
+		exporter.gopExceedsMax,
+		exporter.width,
+		exporter.height,
+		exporter.profileIdc,
+		exporter.levelIdc,
+		exporter.chromaFormat,
+		exporter.bitDepth,
+		exporter.videoInfo,
+		exporter.audioOnly,
+		exporter.audioBitrate,
+		exporter.audioSampleRate,
+		exporter.audioChannels,
+		exporter.audioJitter,
+		exporter.audioGaps,
+		exporter.audioGapMax,
+		exporter.audioInfo,
+		exporter.metadataInfo,
+		exporter.metadataFramerate,
+		exporter.metadataVideoBitrate,
+		exporter.metadataAudioBitrate,
+		exporter.metadataMismatch,
+		exporter.avSyncOffset,
+		exporter.avSyncDrift,
+		exporter.clockDrift,
+		exporter.e2eLatency,
+		exporter.frozenSuspected,
+		exporter.frozenTinyRun,
+		exporter.interFrameMedianBytes,
+		exporter.interFrameSizeRatio,
+		exporter.contentAnalyzed,
+		exporter.contentBlackRatio,
+		exporter.contentLumaMean,
+		exporter.contentLumaVariance,
+		exporter.contentSimilarity,
+		exporter.contentIssue,
 		exporter.qualityScore,
 		exporter.stabilityScore,
 		exporter.overallScore,
+		exporter.firstVideoPacket,
+		exporter.firstKeyframe,
+		exporter.firstGOP,
+		exporter.startupLatency,
+		exporter.frameDTSInterval,
+		exporter.frameArrivalInterval,
+		exporter.dtsDiscontinuities,
+		exporter.dtsBackward,
+		exporter.dnsLookup,
+		exporter.tcpConnect,
+		exporter.tlsHandshake,
+		exporter.serverProcessing,
+		exporter.connReused,
 		exporter.ttfb,
 		exporter.readThroughput,
 		exporter.readStallCount,
 		exporter.readStallMax,
 		exporter.readStallTotal,
 		exporter.readStallRatio,
+		exporter.gopCacheBurst,
+		exporter.burstBytes,
+		exporter.burstDuration,
+		exporter.burstMedia,
+		exporter.steadyThroughput,
+		exporter.steadyBitrate,
+		exporter.hlsPlaylistRefresh,
+		exporter.hlsSegmentDownload,
+		exporter.hlsSegmentDownloadMax,
+		exporter.hlsSegmentRatio,
+		exporter.hlsSegments,
+		exporter.hlsSequenceResets,
+		exporter.hlsTargetDuration,
+		exporter.rtmpTCPConnect,
+		exporter.rtmpHandshake,
+		exporter.rtmpConnect,
+		exporter.rtmpPlay,
+		exporter.rtpPackets,
+		exporter.rtpLostPackets,
+		exporter.rtpSeqGaps,
+		exporter.rtpLossRatio,
+		exporter.rtpReordered,
+		exporter.rtspInfo,
+		exporter.tlsInfo,
+		exporter.tlsCertNotAfter,
+		exporter.tlsCertVerified,
+		exporter.tlsHostnameMatch,
+		exporter.tlsOCSPStapled,
+		exporter.remoteInfo,
+		exporter.edgeUp,
+		exporter.edgeResponse,
+		exporter.edgeTCPConnect,
+		exporter.edgeTTFB,
+		exporter.edgeReadThroughput,
+		exporter.edgeReadStallRatio,
+		exporter.edgeBitrate,
+		exporter.edgeFramerate,
 	)
 
+	// 直方图和计数器在每次检查成功时记录（不依赖抓取，两次抓取之间的检查都会记录）
+	scheduler.OnCheckComplete(exporter.observeCheck)
+
 	return exporter
 }
 
-// updateMetrics 更新指标
+// updateMetrics 更新指标（调用方需持有 scrapeMu）
 func (e *Exporter) updateMetrics() {
 	e.log.Debug("开始更新指标")
 	metrics := e.scheduler.GetAllMetrics()
 	e.log.Debug("获取到指标", "数量", len(metrics))
 
+	// 信息类指标的标签值会变化，每次更新前清空，避免残留旧的标签组合
+	e.videoInfo.Reset()
+	e.rtspInfo.Reset()
+	e.audioInfo.Reset()
+	e.metadataInfo.Reset()
+	e.remoteInfo.Reset()
+	e.resetTLSMetrics()
+	e.resetEdgeMetrics()
+
 	for _, m := range metrics {
-		// 获取 Prometheus 标签（基础标签 + 白名单标签）
-		promLabels := getPrometheusLabels(m)
-
-		// 构建标签值列表（按固定顺序：project, line, id, table, desk, biz, isp, role）
-		labelValues := []string{
-			promLabels["project"],
-			promLabels["line"],
-			promLabels["id"],
-			promLabels["table"], // 可能为空
-			promLabels["desk"],  // 可能为空
-			promLabels["biz"],   // 可能为空
-			promLabels["isp"],   // 可能为空
-			promLabels["role"],  // 可能为空
-		}
+		labelValues := getPrometheusLabelValues(m)
 
 		// 流状态
 		upValue := 0.0
@@ -355,12 +1354,133 @@ func (e *Exporter) updateMetrics() {
 		// 码率指标
 		e.currentBitrate.WithLabelValues(labelValues...).Set(m.CurrentBitrate)
 		e.avgBitrate.WithLabelValues(labelValues...).Set(m.AvgBitrate)
+		e.videoBitrate.WithLabelValues(labelValues...).Set(m.VideoBitrate)
 
 		// 其他质量指标
 		e.framerate.WithLabelValues(labelValues...).Set(m.Framerate)
 		e.responseTime.WithLabelValues(labelValues...).Set(float64(m.Response))
 		e.gopSize.WithLabelValues(labelValues...).Set(float64(m.GOPSize))
 
+		// GOP 时长
+		e.gopFramesMin.WithLabelValues(labelValues...).Set(float64(m.GOP.FramesMin))
+		e.gopFramesMax.WithLabelValues(labelValues...).Set(float64(m.GOP.FramesMax))
+		e.gopDurationMin.WithLabelValues(labelValues...).Set(m.GOP.DurationMinSec)
+		e.gopDurationMax.WithLabelValues(labelValues...).Set(m.GOP.DurationMaxSec)
+		e.gopDurationMean.WithLabelValues(labelValues...).Set(m.GOP.DurationMeanSec)
+		e.gopDurationVar.WithLabelValues(labelValues...).Set(m.GOP.DurationVariance)
+		gopExceedsValue := 0.0
+		if m.GOP.ExceedsMax {
+			gopExceedsValue = 1.0
+		}
+		e.gopExceedsMax.WithLabelValues(labelValues...).Set(gopExceedsValue)
+
+		// 视频参数
+		e.width.WithLabelValues(labelValues...).Set(float64(m.Width))
+		e.height.WithLabelValues(labelValues...).Set(float64(m.Height))
+		e.profileIdc.WithLabelValues(labelValues...).Set(float64(m.VideoInfo.ProfileIdc))
+		e.levelIdc.WithLabelValues(labelValues...).Set(float64(m.VideoInfo.LevelIdc))
+		e.chromaFormat.WithLabelValues(labelValues...).Set(float64(m.VideoInfo.ChromaFormat))
+		e.bitDepth.WithLabelValues(labelValues...).Set(float64(m.VideoInfo.BitDepthLuma))
+		if m.Codec != "" {
+			bitDepth := ""
+			if m.VideoInfo.BitDepthLuma > 0 {
+				bitDepth = strconv.Itoa(m.VideoInfo.BitDepthLuma)
+			}
+			infoValues := append(append([]string{}, labelValues...),
+				m.Codec, m.VideoInfo.Profile, m.VideoInfo.Level, m.VideoInfo.Resolution(), m.VideoInfo.ChromaFormatName(), bitDepth)
+			e.videoInfo.WithLabelValues(infoValues...).Set(1)
+		}
+
+		// 音频指标
+		audioOnlyValue := 0.0
+		if m.AudioOnly {
+			audioOnlyValue = 1.0
+		}
+		e.audioOnly.WithLabelValues(labelValues...).Set(audioOnlyValue)
+		e.audioBitrate.WithLabelValues(labelValues...).Set(m.Audio.BitrateBps)
+		e.audioSampleRate.WithLabelValues(labelValues...).Set(float64(m.Audio.Info.SampleRate))
+		e.audioChannels.WithLabelValues(labelValues...).Set(float64(m.Audio.Info.Channels))
+		e.audioJitter.WithLabelValues(labelValues...).Set(m.Audio.JitterMs)
+		e.audioGaps.WithLabelValues(labelValues...).Set(float64(m.Audio.Gaps))
+		e.audioGapMax.WithLabelValues(labelValues...).Set(m.Audio.GapMaxMs)
+		if info := m.Audio.Info; info.Codec != "" {
+			infoValues := append(append([]string{}, labelValues...),
+				info.Codec, info.Profile, strconv.Itoa(info.SampleRate), strconv.Itoa(info.Channels))
+			e.audioInfo.WithLabelValues(infoValues...).Set(1)
+		}
+
+		// onMetaData 声明的编码参数
+		e.metadataFramerate.WithLabelValues(labelValues...).Set(m.Metadata.Framerate)
+		e.metadataVideoBitrate.WithLabelValues(labelValues...).Set(m.Metadata.VideoDataRateKbps * 1000)
+		e.metadataAudioBitrate.WithLabelValues(labelValues...).Set(m.Metadata.AudioDataRateKbps * 1000)
+		if md := m.Metadata; md.Present {
+			infoValues := append(append([]string{}, labelValues...),
+				md.Encoder, formatMetadataNumber(float64(md.Width)), formatMetadataNumber(float64(md.Height)),
+				formatMetadataNumber(md.Framerate), formatMetadataNumber(md.VideoDataRateKbps), formatMetadataNumber(md.AudioDataRateKbps))
+			e.metadataInfo.WithLabelValues(infoValues...).Set(1)
+		}
+		mismatches := []struct {
+			field    string
+			mismatch bool
+		}{
+			{"framerate", m.MetadataMismatch.Framerate},
+			{"video_bitrate", m.MetadataMismatch.VideoBitrate},
+			{"audio_bitrate", m.MetadataMismatch.AudioBitrate},
+		}
+		for _, mm := range mismatches {
+			mismatchValue := 0.0
+			if mm.mismatch {
+				mismatchValue = 1.0
+			}
+			values := append(append([]string{}, labelValues...), mm.field)
+			e.metadataMismatch.WithLabelValues(values...).Set(mismatchValue)
+		}
+
+		// 音画同步
+		e.avSyncOffset.WithLabelValues(labelValues...).Set(m.AVSync.OffsetMs)
+		e.avSyncDrift.WithLabelValues(labelValues...).Set(m.AVSync.DriftMsPerSec)
+
+		// 直播延迟
+		e.clockDrift.WithLabelValues(labelValues...).Set(m.Latency.ClockDriftMsPerSec)
+		e.e2eLatency.WithLabelValues(labelValues...).Set(m.Latency.E2ELatencyMs)
+
+		// 画面静止
+		frozenSuspected := 0.0
+		if m.Frozen.Suspected {
+			frozenSuspected = 1.0
+		}
+		e.frozenSuspected.WithLabelValues(labelValues...).Set(frozenSuspected)
+		e.frozenTinyRun.WithLabelValues(labelValues...).Set(m.Frozen.TinyRunSec)
+		e.interFrameMedianBytes.WithLabelValues(labelValues...).Set(m.Frozen.InterFrameMedianBytes)
+		e.interFrameSizeRatio.WithLabelValues(labelValues...).Set(m.Frozen.SizeRatio)
+
+		// 画面内容（解码关键帧）
+		contentAnalyzed := 0.0
+		if m.Content.Analyzed {
+			contentAnalyzed = 1.0
+		}
+		e.contentAnalyzed.WithLabelValues(labelValues...).Set(contentAnalyzed)
+		e.contentBlackRatio.WithLabelValues(labelValues...).Set(m.Content.BlackRatio)
+		e.contentLumaMean.WithLabelValues(labelValues...).Set(m.Content.LumaMean)
+		e.contentLumaVariance.WithLabelValues(labelValues...).Set(m.Content.LumaVariance)
+		e.contentSimilarity.WithLabelValues(labelValues...).Set(m.Content.Similarity)
+		contentIssues := []struct {
+			issue    string
+			detected bool
+		}{
+			{contentIssueBlack, m.Content.Black},
+			{contentIssueBlank, m.Content.Blank},
+			{contentIssueFrozen, m.Content.Frozen},
+		}
+		for _, ci := range contentIssues {
+			issueValue := 0.0
+			if ci.detected {
+				issueValue = 1.0
+			}
+			values := append(append([]string{}, labelValues...), ci.issue)
+			e.contentIssue.WithLabelValues(values...).Set(issueValue)
+		}
+
 		// 质量评分
 		qualityScore := 0.0
 		switch m.Quality {
@@ -423,32 +1543,227 @@ func (e *Exporter) updateMetrics() {
 		}
 		e.overallScore.WithLabelValues(labelValues...).Set(overallScore)
 
+		// 起播耗时
+		e.firstVideoPacket.WithLabelValues(labelValues...).Set(m.Startup.FirstVideoPacketMs)
+		e.firstKeyframe.WithLabelValues(labelValues...).Set(m.Startup.FirstKeyframeMs)
+		e.firstGOP.WithLabelValues(labelValues...).Set(m.Startup.FirstGOPMs)
+
 		// 网络指标
 		// response_ms: HTTP 响应头返回时间（在 responseTime 指标中，已在上方设置）
 		// ttfb_ms: 首字节时间（从请求开始到第一个数据包读取的时间）
+		e.dnsLookup.WithLabelValues(labelValues...).Set(m.Transport.HTTP.DNSMs)
+		e.tcpConnect.WithLabelValues(labelValues...).Set(m.ConnectLatencyMs)
+		e.tlsHandshake.WithLabelValues(labelValues...).Set(m.Transport.HTTP.TLSMs)
+		e.serverProcessing.WithLabelValues(labelValues...).Set(m.Transport.HTTP.ServerMs)
+		reusedValue := 0.0
+		if m.Transport.HTTP.Reused {
+			reusedValue = 1.0
+		}
+		e.connReused.WithLabelValues(labelValues...).Set(reusedValue)
 		e.ttfb.WithLabelValues(labelValues...).Set(m.TTFBMs)
 		e.readThroughput.WithLabelValues(labelValues...).Set(m.ReadThroughputBps)
 		e.readStallCount.WithLabelValues(labelValues...).Set(float64(m.ReadStallCount))
 		e.readStallMax.WithLabelValues(labelValues...).Set(m.ReadStallMaxMs)
 		e.readStallTotal.WithLabelValues(labelValues...).Set(m.ReadStallTotalMs)
 		e.readStallRatio.WithLabelValues(labelValues...).Set(m.ReadStallRatio)
+
+		// 初始突发（GOP 缓存）与稳态速率
+		burstValue := 0.0
+		if m.Burst.Detected {
+			burstValue = 1.0
+		}
+		e.gopCacheBurst.WithLabelValues(labelValues...).Set(burstValue)
+		e.burstBytes.WithLabelValues(labelValues...).Set(float64(m.Burst.Bytes))
+		e.burstDuration.WithLabelValues(labelValues...).Set(m.Burst.DurationMs)
+		e.burstMedia.WithLabelValues(labelValues...).Set(m.Burst.MediaMs)
+		e.steadyThroughput.WithLabelValues(labelValues...).Set(m.Burst.SteadyThroughputBps)
+		e.steadyBitrate.WithLabelValues(labelValues...).Set(m.Burst.SteadyBitrateBps)
+
+		// 协议专属指标，只对对应协议的流输出
+		switch m.Protocol {
+		case "hls":
+			e.hlsPlaylistRefresh.WithLabelValues(labelValues...).Set(m.Transport.HLS.PlaylistRefreshMs)
+			e.hlsSegmentDownload.WithLabelValues(labelValues...).Set(m.Transport.HLS.SegmentDownloadMs)
+			e.hlsSegmentDownloadMax.WithLabelValues(labelValues...).Set(m.Transport.HLS.SegmentDownloadMaxMs)
+			e.hlsSegmentRatio.WithLabelValues(labelValues...).Set(m.Transport.HLS.SegmentDownloadRatio)
+			e.hlsSegments.WithLabelValues(labelValues...).Set(float64(m.Transport.HLS.SegmentCount))
+			e.hlsSequenceResets.WithLabelValues(labelValues...).Set(float64(m.Transport.HLS.SequenceResets))
+			e.hlsTargetDuration.WithLabelValues(labelValues...).Set(m.Transport.HLS.TargetDurationSec)
+		case "rtmp":
+			e.rtmpTCPConnect.WithLabelValues(labelValues...).Set(m.Transport.RTMP.TCPConnectMs)
+			e.rtmpHandshake.WithLabelValues(labelValues...).Set(m.Transport.RTMP.HandshakeMs)
+			e.rtmpConnect.WithLabelValues(labelValues...).Set(m.Transport.RTMP.ConnectMs)
+			e.rtmpPlay.WithLabelValues(labelValues...).Set(m.Transport.RTMP.PlayMs)
+		case "rtsp":
+			e.rtpPackets.WithLabelValues(labelValues...).Set(float64(m.Transport.RTSP.Packets))
+			e.rtpLostPackets.WithLabelValues(labelValues...).Set(float64(m.Transport.RTSP.LostPackets))
+			e.rtpSeqGaps.WithLabelValues(labelValues...).Set(float64(m.Transport.RTSP.SeqGaps))
+			e.rtpLossRatio.WithLabelValues(labelValues...).Set(m.Transport.RTSP.LossRatio)
+			e.rtpReordered.WithLabelValues(labelValues...).Set(float64(m.Transport.RTSP.Reordered))
+			if info := m.Transport.RTSP.Info; info.VideoCodec != "" || info.AudioCodec != "" {
+				infoValues := append(append([]string{}, labelValues...),
+					info.VideoCodec, info.VideoProfile, info.AudioCodec, info.AudioSampleRate, info.AudioChannels)
+				e.rtspInfo.WithLabelValues(infoValues...).Set(1)
+			}
+		}
+
+		// TLS 证书和握手
+		e.setTLSMetrics(labelValues, m.TLS)
+
+		// 边缘节点
+		if m.RemoteIP != "" {
+			e.remoteInfo.WithLabelValues(append(append([]string{}, labelValues...), m.RemoteIP)...).Set(1)
+		}
+		e.setEdgeMetrics(labelValues, m.Edges)
 	}
 
 	e.log.Debug("指标更新完成")
 }
 
+// formatMetadataNumber 格式化 onMetaData 中的数值标签（0 表示未声明，输出空字符串）
+func formatMetadataNumber(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// observeCheck 记录一次成功检查的起播耗时、帧间隔和时间戳跳变（由调度器在检查完成时调用）
+func (e *Exporter) observeCheck(m StreamMetrics) {
+	labelValues := getPrometheusLabelValues(m)
+	e.observeStartup(labelValues, m.Startup)
+	e.observeFrameIntervals(labelValues, m.FrameIntervals)
+	e.addTimestampCounters(labelValues, m.Timestamps)
+}
+
+// observeStartup 记录起播耗时直方图（未达到的阶段不记录）
+func (e *Exporter) observeStartup(labelValues []string, s StartupStats) {
+	stages := []struct {
+		name string
+		ms   float64
+	}{
+		{"first_video_packet", s.FirstVideoPacketMs},
+		{"first_keyframe", s.FirstKeyframeMs},
+		{"first_gop", s.FirstGOPMs},
+	}
+	for _, stage := range stages {
+		if stage.ms <= 0 {
+			continue
+		}
+		values := append(append([]string{}, labelValues...), stage.name)
+		e.startupLatency.WithLabelValues(values...).Observe(stage.ms)
+	}
+}
+
+// observeFrameIntervals 记录本次检查的帧间隔样本
+func (e *Exporter) observeFrameIntervals(labelValues []string, intervals FrameIntervals) {
+	dtsInterval := e.frameDTSInterval.WithLabelValues(labelValues...)
+	for _, ms := range intervals.DTSMs {
+		dtsInterval.Observe(ms)
+	}
+	arrivalInterval := e.frameArrivalInterval.WithLabelValues(labelValues...)
+	for _, ms := range intervals.ArrivalMs {
+		arrivalInterval.Observe(ms)
+	}
+}
+
+// addTimestampCounters 累加本次检查的 DTS 跳变和回退次数
+func (e *Exporter) addTimestampCounters(labelValues []string, t TimestampStats) {
+	tracks := []struct {
+		name            string
+		discontinuities int
+		backward        int
+	}{
+		{"video", t.VideoDiscontinuities, t.VideoBackward},
+		{"audio", t.AudioDiscontinuities, t.AudioBackward},
+	}
+	for _, track := range tracks {
+		values := append(append([]string{}, labelValues...), track.name)
+		e.dtsDiscontinuities.WithLabelValues(values...).Add(float64(track.discontinuities))
+		e.dtsBackward.WithLabelValues(values...).Add(float64(track.backward))
+	}
+}
+
+// resetTLSMetrics 清空 TLS 指标（只输出拿到了对端证书的流，避免非 TLS 流出现过期时间为 0 的序列）
+func (e *Exporter) resetTLSMetrics() {
+	e.tlsInfo.Reset()
+	e.tlsCertNotAfter.Reset()
+	e.tlsCertVerified.Reset()
+	e.tlsHostnameMatch.Reset()
+	e.tlsOCSPStapled.Reset()
+}
+
+// setTLSMetrics 设置对端证书和握手信息（没有拿到证书时不输出）
+func (e *Exporter) setTLSMetrics(labelValues []string, info TLSInfo) {
+	if !info.Present {
+		return
+	}
+	boolValue := func(b bool) float64 {
+		if b {
+			return 1.0
+		}
+		return 0.0
+	}
+	infoValues := append(append([]string{}, labelValues...), info.Version, info.CipherSuite, info.Issuer)
+	e.tlsInfo.WithLabelValues(infoValues...).Set(1)
+	e.tlsCertNotAfter.WithLabelValues(labelValues...).Set(float64(info.NotAfter.Unix()))
+	e.tlsCertVerified.WithLabelValues(labelValues...).Set(boolValue(info.Verified))
+	e.tlsHostnameMatch.WithLabelValues(labelValues...).Set(boolValue(info.HostnameMatch))
+	e.tlsOCSPStapled.WithLabelValues(labelValues...).Set(boolValue(info.OCSPStapled))
+}
+
+// resetEdgeMetrics 清空逐节点指标（解析结果会变化，避免残留已经不存在的节点）
+func (e *Exporter) resetEdgeMetrics() {
+	e.edgeUp.Reset()
+	e.edgeResponse.Reset()
+	e.edgeTCPConnect.Reset()
+	e.edgeTTFB.Reset()
+	e.edgeReadThroughput.Reset()
+	e.edgeReadStallRatio.Reset()
+	e.edgeBitrate.Reset()
+	e.edgeFramerate.Reset()
+}
+
+// setEdgeMetrics 设置 probe_all_edges 各边缘节点的检查结果（还没有完成检查的节点不输出）
+func (e *Exporter) setEdgeMetrics(labelValues []string, edges []StreamMetrics) {
+	for _, edge := range edges {
+		if edge.LastCheckTime.IsZero() {
+			continue
+		}
+		values := append(append([]string{}, labelValues...), edge.EdgeIP)
+		upValue := 0.0
+		if edge.Healthy {
+			upValue = 1.0
+		}
+		e.edgeUp.WithLabelValues(values...).Set(upValue)
+		e.edgeResponse.WithLabelValues(values...).Set(float64(edge.Response))
+		e.edgeTCPConnect.WithLabelValues(values...).Set(edge.ConnectLatencyMs)
+		e.edgeTTFB.WithLabelValues(values...).Set(edge.TTFBMs)
+		e.edgeReadThroughput.WithLabelValues(values...).Set(edge.ReadThroughputBps)
+		e.edgeReadStallRatio.WithLabelValues(values...).Set(edge.ReadStallRatio)
+		e.edgeBitrate.WithLabelValues(values...).Set(edge.CurrentBitrate)
+		e.edgeFramerate.WithLabelValues(values...).Set(edge.Framerate)
+	}
+}
+
 // StartHTTPServer 启动 HTTP 服务器
 func (e *Exporter) StartHTTPServer(addr string) error {
 	mux := http.NewServeMux()
 
-	// Prometheus metrics endpoint - 每次请求时更新指标
+	// Prometheus metrics endpoint - 每次请求时更新指标（更新和输出在同一把锁内完成）
+	metricsHandler := promhttp.Handler()
 	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
 		e.log.Debug("收到 metrics 请求")
+		e.scrapeMu.Lock()
+		defer e.scrapeMu.Unlock()
 		e.updateMetrics()
 		e.log.Debug("指标更新完成")
-		promhttp.Handler().ServeHTTP(w, r)
+		metricsHandler.ServeHTTP(w, r)
 	})
 
+	// 关键帧快照：/streams/{project:line:id}/snapshot?format=jpeg|h264
+	mux.HandleFunc("GET /streams/{key}/snapshot", e.handleSnapshot)
+
 	// 首页
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
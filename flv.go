@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nareix/joy5/av"
+	"github.com/nareix/joy5/format/flv"
+	"github.com/nareix/joy5/format/flv/flvio"
+)
+
+// flvProber HTTP-FLV 拉流
+type flvProber struct {
+	url      string
+	tracking *stallTrackingReader
+
+	resp    *http.Response
+	demuxer *flv.Demuxer
+
+	responseTime time.Duration   // HTTP 响应头返回时间
+	trace        httpTimingTrace // DNS / TCP / TLS / 服务端处理耗时
+	tls          TLSInfo         // 对端证书和 TLS 握手信息
+}
+
+// newFLVProber 创建 HTTP-FLV Prober
+func newFLVProber(rawURL string, tracking *stallTrackingReader) Prober {
+	return &flvProber{url: rawURL, tracking: tracking}
+}
+
+// Open 发起 HTTP 请求，收到 200 响应头后开始解析 FLV
+func (p *flvProber) Open(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(p.trace.withContext(ctx), "GET", p.url, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	setRequestHeader(req)
+
+	// 使用全局HTTP客户端，复用连接池（context 超时会自动取消）
+	reqStart := time.Now()
+	resp, err := httpClientFor(ctx).Do(req)
+	p.responseTime = time.Since(reqStart) // HTTP 响应头返回时间
+	p.tls = httpTLSInfo(resp, err, req.URL.Hostname())
+	if err != nil {
+		// 检查是否是超时错误
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("请求超时: %w", err)
+		}
+		return fmt.Errorf("连接失败: %w", err)
+	}
+	p.resp = resp
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
+	}
+
+	// 创建解复用器（使用包装的 Reader）
+	p.tracking.reader = resp.Body
+	p.demuxer = flv.NewDemuxer(p.tracking)
+	return nil
+}
+
+// ReadPacket 读取下一个音视频包
+func (p *flvProber) ReadPacket() (av.Packet, error) {
+	return readFLVPacket(p.demuxer.ReadTag)
+}
+
+// TransportStats 返回传输层统计
+func (p *flvProber) TransportStats() TransportStats {
+	return TransportStats{ResponseTime: p.responseTime, RemoteIP: p.trace.peerIP(), HTTP: p.trace.timing(), TLS: p.tls}
+}
+
+// Close 关闭响应体
+func (p *flvProber) Close() error {
+	if p.resp == nil {
+		return nil
+	}
+	return p.resp.Body.Close()
+}
+
+// 增强型 FLV（Enhanced RTMP）视频 PacketType
+const (
+	flvExPacketSequenceStart = 0 // 解码配置（hvcC / av1C / vpcC / avcC）
+	flvExPacketCodedFrames   = 1 // 编码帧（hvc1 / avc1 带 3 字节 CTS）
+	flvExPacketCodedFramesX  = 3 // 编码帧（CTS 为 0，不带 CTS 字段）
+)
+
+// errFLVTagSkipped 交给 joy5 的 tag 不产生数据包（内部使用）
+var errFLVTagSkipped = errors.New("tag skipped")
+
+// readFLVPacket 从 FLV tag 流读取下一个数据包
+// joy5 的 flv.ReadPacket 只识别 H.264，这里自行解析视频 tag，额外支持：
+//   - 传统 FLV 的 HEVC（CodecID=12，国内 CDN 常用的扩展）
+//   - 增强型 FLV 的 FourCC：hvc1 / av01 / vp09 / avc1
+//
+// 音频和 metadata tag 仍交给 joy5 处理
+func readFLVPacket(readTag func() (flvio.Tag, error)) (av.Packet, error) {
+	for {
+		tag, err := readTag()
+		if err != nil {
+			return av.Packet{}, err
+		}
+
+		if tag.Type == flvio.TAG_VIDEO {
+			if pkt, ok := parseFLVVideoTag(tag); ok {
+				return pkt, nil
+			}
+			continue
+		}
+
+		used := false
+		pkt, err := flv.ReadPacket(func() (flvio.Tag, error) {
+			if used {
+				return flvio.Tag{}, errFLVTagSkipped
+			}
+			used = true
+			return tag, nil
+		})
+		if err == errFLVTagSkipped {
+			continue
+		}
+		return pkt, err
+	}
+}
+
+// parseFLVVideoTag 将视频 tag 转换为数据包，不支持的编码或 PacketType 返回 false
+func parseFLVVideoTag(tag flvio.Tag) (av.Packet, bool) {
+	// joy5 按传统格式切分了 Header/Data（两者来自同一缓冲），这里恢复完整的 tag body 重新解析
+	body := tag.Header[:len(tag.Header)+len(tag.Data)]
+	if len(body) < 1 {
+		return av.Packet{}, false
+	}
+	frameType := (body[0] >> 4) & 0x07
+	pkt := av.Packet{
+		Time:       flvio.TsToTime(int64(tag.Time)),
+		IsKeyFrame: frameType == flvio.FRAME_KEY,
+	}
+
+	// 增强型 FLV：IsExHeader(1) FrameType(3) PacketType(4) FourCC(32)
+	if body[0]&0x80 != 0 {
+		if len(body) < 5 {
+			return av.Packet{}, false
+		}
+		packetType := body[0] & 0x0f
+		fourCC := string(body[1:5])
+		data := body[5:]
+
+		var dataType, configType int
+		switch fourCC {
+		case "avc1":
+			dataType, configType = av.H264, av.H264DecoderConfig
+		case "hvc1":
+			dataType, configType = pktH265, pktH265DecoderConfig
+		case "av01":
+			dataType, configType = pktAV1, pktAV1DecoderConfig
+		case "vp09":
+			dataType, configType = pktVP9, pktVP9DecoderConfig
+		default:
+			return av.Packet{}, false
+		}
+
+		switch packetType {
+		case flvExPacketSequenceStart:
+			return av.Packet{Type: configType, Data: data}, true
+		case flvExPacketCodedFrames:
+			// 只有 avc1 / hvc1 带 CTS
+			if fourCC == "avc1" || fourCC == "hvc1" {
+				if len(data) < 3 {
+					return av.Packet{}, false
+				}
+				cts := int32(data[0])<<16 | int32(data[1])<<8 | int32(data[2])
+				cts = cts << 8 >> 8 // 24 位有符号数
+				pkt.CTime = flvio.TsToTime(int64(cts))
+				data = data[3:]
+			}
+		case flvExPacketCodedFramesX:
+		default:
+			// SequenceEnd / Metadata / MPEG2TSSequenceStart 等不产生数据包
+			return av.Packet{}, false
+		}
+		pkt.Type = dataType
+		pkt.Data = data
+		return pkt, true
+	}
+
+	// 传统 FLV：FrameType(4) CodecID(4) AVCPacketType(8) CompositionTime(24)
+	switch tag.VideoFormat {
+	case flvio.VIDEO_H264:
+		pkt.Type = av.H264
+		if tag.AVCPacketType == flvio.AVC_SEQHDR {
+			return av.Packet{Type: av.H264DecoderConfig, Data: tag.Data}, true
+		}
+	case flvio.VIDEO_H265:
+		pkt.Type = pktH265
+		if tag.AVCPacketType == flvio.AVC_SEQHDR {
+			return av.Packet{Type: pktH265DecoderConfig, Data: tag.Data}, true
+		}
+	default:
+		return av.Packet{}, false
+	}
+	if tag.AVCPacketType != flvio.AVC_NALU {
+		return av.Packet{}, false
+	}
+	pkt.CTime = flvio.TsToTime(int64(tag.CTime))
+	pkt.Data = tag.Data
+	return pkt, true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// 画面静止的判定参数
+const (
+	frozenTinyFrameBytes = 128 // 非关键帧负载不超过此字节数视为空帧（画面不变时编码器输出的全跳过 P 帧通常只有几十字节）
+	frozenCollapseRatio  = 0.1 // 非关键帧大小中位数低于历史基线的此比例视为骤降
+	frozenMinHistory     = 3   // 至少有这么多次正常检查的历史才与基线比较
+	frozenHistorySize    = 10  // 历史基线保留最近的检查次数
+)
+
+// getFrozenMinDuration 获取连续空帧的时长阈值（从配置读取，默认2000ms）
+func getFrozenMinDuration() time.Duration {
+	if globalConfig != nil && globalConfig.Exporter.FrozenMinDurationMs > 0 {
+		return time.Duration(globalConfig.Exporter.FrozenMinDurationMs) * time.Millisecond
+	}
+	return 2000 * time.Millisecond // 默认值
+}
+
+// 疑似画面静止的原因
+const (
+	frozenReasonTinyFrames   = "tiny_frames"   // 连续空帧超过时长阈值
+	frozenReasonSizeCollapse = "size_collapse" // 非关键帧大小相对历史骤降
+)
+
+// FrozenStats 基于帧大小的画面静止判定（本次检查的值，不解码）
+// 编码器画面卡住时码率和帧率可能仍然正常，但非关键帧几乎为空
+type FrozenStats struct {
+	Suspected             bool    // 是否疑似画面静止
+	Reason                string  // 判定原因（tiny_frames / size_collapse），未判定时为空
+	TinyRunSec            float64 // 最长连续空帧时长（按 DTS，秒），关键帧不打断连续空帧
+	InterFrameMedianBytes float64 // 非关键帧负载大小中位数
+	SizeRatio             float64 // 本次中位数 / 历史基线，历史不足时为 0
+}
+
+// frozenTracker 记录非关键帧大小和连续空帧时长
+type frozenTracker struct {
+	sizes    []int // 非关键帧负载大小
+	inRun    bool
+	runStart time.Duration // 当前连续空帧第一帧的 DTS
+	maxRun   time.Duration
+}
+
+// observe 记录一个视频包
+// 画面静止时编码器通常仍按 GOP 周期输出关键帧，关键帧既不计入也不打断连续空帧
+func (f *frozenTracker) observe(dts time.Duration, size int, keyframe bool) {
+	if keyframe {
+		return
+	}
+	f.sizes = append(f.sizes, size)
+	if size > frozenTinyFrameBytes {
+		f.inRun = false
+		return
+	}
+	if !f.inRun {
+		f.inRun = true
+		f.runStart = dts
+	}
+	f.maxRun = max(f.maxRun, dts-f.runStart)
+}
+
+// stats 判定是否疑似画面静止，history 为之前正常检查的非关键帧大小中位数
+func (f *frozenTracker) stats(history []float64, minRun time.Duration) FrozenStats {
+	stats := FrozenStats{TinyRunSec: f.maxRun.Seconds()}
+	if len(f.sizes) == 0 {
+		return stats
+	}
+	sorted := slices.Clone(f.sizes)
+	slices.Sort(sorted)
+	stats.InterFrameMedianBytes = float64(sorted[len(sorted)/2])
+
+	if len(history) >= frozenMinHistory {
+		baseline := 0.0
+		for _, v := range history {
+			baseline += v
+		}
+		baseline /= float64(len(history))
+		if baseline > 0 {
+			stats.SizeRatio = stats.InterFrameMedianBytes / baseline
+		}
+	}
+
+	switch {
+	case f.maxRun >= minRun:
+		stats.Suspected, stats.Reason = true, frozenReasonTinyFrames
+	case stats.SizeRatio > 0 && stats.SizeRatio < frozenCollapseRatio:
+		stats.Suspected, stats.Reason = true, frozenReasonSizeCollapse
+	}
+	return stats
+}
+
+// evaluateFrozenQuality 疑似画面静止时质量为 poor（码率和帧率正常也无法观看）
+func evaluateFrozenQuality(quality string, frozen FrozenStats) string {
+	if frozen.Suspected {
+		return "poor"
+	}
+	return quality
+}
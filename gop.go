@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// getMaxGOPDuration 获取 GOP 时长上限（从配置读取，默认 0 表示不检查）
+func getMaxGOPDuration() time.Duration {
+	if globalConfig != nil && globalConfig.Exporter.MaxGOPDurationMs > 0 {
+		return time.Duration(globalConfig.Exporter.MaxGOPDurationMs) * time.Millisecond
+	}
+	return 0
+}
+
+// GOPStats 关键帧间隔统计（本次检查的值）
+// 只统计采样窗口内完整的 GOP（相邻两个关键帧之间），窗口开头和结尾不完整的部分不计入
+type GOPStats struct {
+	Count            int     // 完整 GOP 个数
+	FramesMin        int     // 最小 GOP 帧数
+	FramesMax        int     // 最大 GOP 帧数
+	FramesMean       float64 // 平均 GOP 帧数
+	DurationMinSec   float64 // 最短 GOP 时长（按 DTS，秒）
+	DurationMaxSec   float64 // 最长 GOP 时长（按 DTS，秒）
+	DurationMeanSec  float64 // 平均 GOP 时长（秒）
+	DurationVariance float64 // GOP 时长方差（秒²）
+	ExceedsMax       bool    // GOP 时长超过 max_gop_duration_ms（含最后一个关键帧之后未结束的 GOP）
+}
+
+// gopTracker 按 DTS 统计相邻关键帧之间的帧数和时长
+type gopTracker struct {
+	started     bool
+	keyframeDTS time.Duration // 当前 GOP 关键帧的 DTS
+	frames      int           // 当前 GOP 已收到的帧数（含关键帧）
+	lastDTS     time.Duration
+
+	durations   []time.Duration // 完整 GOP 的时长
+	frameCounts []int           // 完整 GOP 的帧数
+}
+
+// observe 记录一个视频包
+func (g *gopTracker) observe(dts time.Duration, keyframe bool) {
+	if keyframe {
+		// DTS 回退（时间戳重置）时无法计算时长，丢弃这个 GOP
+		if g.started && dts > g.keyframeDTS {
+			g.durations = append(g.durations, dts-g.keyframeDTS)
+			g.frameCounts = append(g.frameCounts, g.frames)
+		}
+		g.started = true
+		g.keyframeDTS = dts
+		g.frames = 0
+	}
+	if g.started {
+		g.frames++
+	}
+	g.lastDTS = dts
+}
+
+// stats 计算 GOP 统计，maxDuration 为 0 时不检查时长上限
+func (g *gopTracker) stats(maxDuration time.Duration) GOPStats {
+	var stats GOPStats
+	if n := len(g.durations); n > 0 {
+		stats.Count = n
+		stats.FramesMin, stats.FramesMax = g.frameCounts[0], g.frameCounts[0]
+		stats.DurationMinSec, stats.DurationMaxSec = g.durations[0].Seconds(), g.durations[0].Seconds()
+		frames, sum := 0, 0.0
+		for i, d := range g.durations {
+			sec := d.Seconds()
+			sum += sec
+			frames += g.frameCounts[i]
+			stats.DurationMinSec = math.Min(stats.DurationMinSec, sec)
+			stats.DurationMaxSec = math.Max(stats.DurationMaxSec, sec)
+			stats.FramesMin = min(stats.FramesMin, g.frameCounts[i])
+			stats.FramesMax = max(stats.FramesMax, g.frameCounts[i])
+		}
+		stats.FramesMean = float64(frames) / float64(n)
+		stats.DurationMeanSec = sum / float64(n)
+		for _, d := range g.durations {
+			diff := d.Seconds() - stats.DurationMeanSec
+			stats.DurationVariance += diff * diff
+		}
+		stats.DurationVariance /= float64(n)
+	}
+
+	if maxDuration > 0 {
+		// 最后一个关键帧之后未结束的 GOP 已经超过上限时，同样判定为超标（例如 GOP 长于采样窗口）
+		openGOP := time.Duration(0)
+		if g.started {
+			openGOP = g.lastDTS - g.keyframeDTS
+		}
+		stats.ExceedsMax = stats.DurationMaxSec > maxDuration.Seconds() || openGOP > maxDuration
+	}
+	return stats
+}
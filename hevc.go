@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/nareix/joy5/codec/h264"
+)
+
+// H.265 NALU 类型
+const (
+	h265NALUVPS = 32
+	h265NALUSPS = 33
+	h265NALUPPS = 34
+	h265NALUAUD = 35
+)
+
+// h265NALUType 返回 H.265 NALU 类型（NALU 头第一个字节的 bit1~6）
+func h265NALUType(nalu []byte) int {
+	if len(nalu) == 0 {
+		return -1
+	}
+	return int(nalu[0]>>1) & 0x3f
+}
+
+// h265IsIRAP 是否为随机接入点（BLA / IDR / CRA，NALU 类型 16~21）
+func h265IsIRAP(nalu []byte) bool {
+	typ := h265NALUType(nalu)
+	return typ >= 16 && typ <= 21
+}
+
+// h265ProfileName H.265 general_profile_idc 对应的名称
+func h265ProfileName(profileIdc int) string {
+	switch profileIdc {
+	case 1:
+		return "Main"
+	case 2:
+		return "Main 10"
+	case 3:
+		return "Main Still Picture"
+	case 4:
+		return "Range Extensions"
+	case 5:
+		return "High Throughput"
+	case 9:
+		return "Screen Content"
+	}
+	return fmt.Sprintf("%d", profileIdc)
+}
+
+// h265LevelName H.265 general_level_idc 对应的名称（level_idc = 30 × 级别）
+func h265LevelName(levelIdc int) string {
+	return fmt.Sprintf("%d.%d", levelIdc/30, levelIdc%30/3)
+}
+
+// parseH265SPS 解析 H.265 SPS NALU（含 2 字节 NALU 头），只解析到位深为止
+func parseH265SPS(nalu []byte) (VideoInfo, error) {
+	if len(nalu) < 4 || h265NALUType(nalu) != h265NALUSPS {
+		return VideoInfo{}, fmt.Errorf("不是 SPS")
+	}
+	br := newBitReader(h264.RemoveH264orH265EmulationBytes(nalu[2:]))
+	u, ue := br.u, br.ue
+
+	info := VideoInfo{Codec: "H265"}
+
+	u(4) // sps_video_parameter_set_id
+	maxSubLayersMinus1 := int(u(3))
+	u(1) // sps_temporal_id_nesting_flag
+
+	// profile_tier_level(1, sps_max_sub_layers_minus1)
+	u(2) // general_profile_space
+	u(1) // general_tier_flag
+	profileIdc := int(u(5))
+	br.skip(32) // general_profile_compatibility_flag
+	br.skip(48) // progressive / interlaced / non_packed / frame_only + 44 位保留
+	levelIdc := int(u(8))
+	subLayerProfilePresent := make([]bool, maxSubLayersMinus1)
+	subLayerLevelPresent := make([]bool, maxSubLayersMinus1)
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		subLayerProfilePresent[i] = u(1) != 0
+		subLayerLevelPresent[i] = u(1) != 0
+	}
+	if maxSubLayersMinus1 > 0 {
+		br.skip(2 * (8 - maxSubLayersMinus1)) // reserved_zero_2bits
+	}
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		if subLayerProfilePresent[i] {
+			br.skip(88)
+		}
+		if subLayerLevelPresent[i] {
+			br.skip(8)
+		}
+	}
+
+	ue() // sps_seq_parameter_set_id
+	info.ChromaFormat = int(ue())
+	separateColourPlane := uint(0)
+	if info.ChromaFormat == 3 {
+		separateColourPlane = u(1)
+	}
+	width := int(ue())
+	height := int(ue())
+
+	var cropLeft, cropRight, cropTop, cropBottom int
+	// conformance_window_flag
+	if u(1) != 0 {
+		cropLeft = int(ue())
+		cropRight = int(ue())
+		cropTop = int(ue())
+		cropBottom = int(ue())
+	}
+	info.BitDepthLuma = int(ue()) + 8
+	info.BitDepthChroma = int(ue()) + 8
+	if br.err != nil {
+		return VideoInfo{}, fmt.Errorf("解析 SPS 失败: %w", br.err)
+	}
+
+	// 裁剪单位取决于色度采样格式（见 H.265 表 6-1）
+	subWidthC, subHeightC := 1, 1
+	if separateColourPlane == 0 {
+		switch info.ChromaFormat {
+		case 1:
+			subWidthC, subHeightC = 2, 2
+		case 2:
+			subWidthC = 2
+		}
+	}
+
+	info.ProfileIdc = profileIdc
+	info.Profile = h265ProfileName(profileIdc)
+	info.LevelIdc = levelIdc
+	info.Level = h265LevelName(levelIdc)
+	info.Width = width - subWidthC*(cropLeft+cropRight)
+	info.Height = height - subHeightC*(cropTop+cropBottom)
+	if info.Width <= 0 || info.Height <= 0 || info.Width > 16384 || info.Height > 16384 {
+		return VideoInfo{}, fmt.Errorf("SPS 分辨率无效: %dx%d", info.Width, info.Height)
+	}
+	return info, nil
+}
+
+// hevcDecoderConfigNALUs 返回 HEVCDecoderConfigurationRecord（hvcC）中的参数集 NALU（VPS / SPS / PPS 等）
+func hevcDecoderConfigNALUs(data []byte) ([][]byte, error) {
+	// 固定头 22 字节 + numOfArrays
+	if len(data) < 23 {
+		return nil, fmt.Errorf("HEVC 解码配置过短: %d 字节", len(data))
+	}
+	var nalus [][]byte
+	numArrays := int(data[22])
+	b := data[23:]
+	for i := 0; i < numArrays; i++ {
+		if len(b) < 3 {
+			break
+		}
+		numNALUs := int(binary.BigEndian.Uint16(b[1:]))
+		b = b[3:]
+		for j := 0; j < numNALUs; j++ {
+			if len(b) < 2 {
+				break
+			}
+			size := int(binary.BigEndian.Uint16(b))
+			if 2+size > len(b) {
+				return nil, fmt.Errorf("HEVC 解码配置数据不完整")
+			}
+			nalus = append(nalus, b[2:2+size])
+			b = b[2+size:]
+		}
+	}
+	return nalus, nil
+}
+
+// hevcDecoderConfig 由 VPS / SPS / PPS 生成 HEVCDecoderConfigurationRecord（hvcC）
+// profile_tier_level 从第一个 SPS 中复制，NALU 长度字段为 4 字节
+func hevcDecoderConfig(vps, sps, pps [][]byte) ([]byte, error) {
+	if len(sps) == 0 {
+		return nil, fmt.Errorf("没有 SPS")
+	}
+	info, err := parseH265SPS(sps[0])
+	if err != nil {
+		return nil, err
+	}
+	// SPS RBSP：第 1 字节为 vps_id / max_sub_layers / nesting，之后 12 字节为 general profile_tier_level
+	rbsp := h264.RemoveH264orH265EmulationBytes(sps[0][2:])
+	if len(rbsp) < 13 {
+		return nil, fmt.Errorf("SPS 过短")
+	}
+
+	b := make([]byte, 23, 64)
+	b[0] = 1 // configurationVersion
+	copy(b[1:13], rbsp[1:13])
+	binary.BigEndian.PutUint16(b[13:], 0xf000) // min_spatial_segmentation_idc
+	b[15] = 0xfc                               // parallelismType
+	b[16] = 0xfc | byte(info.ChromaFormat)
+	b[17] = 0xf8 | byte(info.BitDepthLuma-8)
+	b[18] = 0xf8 | byte(info.BitDepthChroma-8)
+	// b[19:21] avgFrameRate = 0
+	b[21] = 0x03 // constantFrameRate / numTemporalLayers = 0，lengthSizeMinusOne = 3
+	for _, array := range []struct {
+		typ   byte
+		nalus [][]byte
+	}{{h265NALUVPS, vps}, {h265NALUSPS, sps}, {h265NALUPPS, pps}} {
+		if len(array.nalus) == 0 {
+			continue
+		}
+		b[22]++
+		b = append(b, 0x80|array.typ) // array_completeness = 1
+		b = binary.BigEndian.AppendUint16(b, uint16(len(array.nalus)))
+		for _, nalu := range array.nalus {
+			b = binary.BigEndian.AppendUint16(b, uint16(len(nalu)))
+			b = append(b, nalu...)
+		}
+	}
+	return b, nil
+}
+
+// parseHEVCDecoderConfig 从 HEVCDecoderConfigurationRecord（hvcC）中解析第一个 SPS
+func parseHEVCDecoderConfig(data []byte) (VideoInfo, error) {
+	nalus, err := hevcDecoderConfigNALUs(data)
+	if err != nil {
+		return VideoInfo{}, err
+	}
+	for _, nalu := range nalus {
+		if h265NALUType(nalu) == h265NALUSPS {
+			return parseH265SPS(nalu)
+		}
+	}
+	return VideoInfo{}, fmt.Errorf("HEVC 解码配置中没有 SPS")
+}
+
+// findH265SPS 在 AVCC 格式的访问单元中查找并解析 SPS（带内参数集）
+func findH265SPS(data []byte) (VideoInfo, bool) {
+	nalus, _ := h264.SplitNALUs(data)
+	for _, nalu := range nalus {
+		if h265NALUType(nalu) == h265NALUSPS {
+			if info, err := parseH265SPS(nalu); err == nil {
+				return info, true
+			}
+		}
+	}
+	return VideoInfo{}, false
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	urlpkg "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nareix/joy5/av"
+)
+
+// hlsLiveEdgeSegments 起播时从播放列表末尾往前取的分片数（与常见播放器行为一致）
+const hlsLiveEdgeSegments = 3
+
+// hlsVariant 多码率播放列表中的一个码率档位
+type hlsVariant struct {
+	uri       string
+	bandwidth int64
+}
+
+// hlsSegment 媒体播放列表中的一个分片
+type hlsSegment struct {
+	uri      string
+	duration time.Duration
+	seq      int64
+}
+
+// hlsPlaylist 解析后的 m3u8 播放列表（multivariant 或 media）
+type hlsPlaylist struct {
+	isMaster       bool
+	variants       []hlsVariant
+	targetDuration time.Duration
+	mediaSequence  int64
+	segments       []hlsSegment
+	endList        bool
+}
+
+// parseHLSAttributes 解析 EXT-X-STREAM-INF 等标签的属性列表（KEY=VALUE,KEY="VALUE"）
+func parseHLSAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value = s[1:]
+				s = ""
+			} else {
+				value = s[1 : end+1]
+				s = s[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				value = s
+				s = ""
+			} else {
+				value = s[:end]
+				s = s[end:]
+			}
+		}
+		attrs[key] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+	return attrs
+}
+
+// parseHLSPlaylist 解析 m3u8 播放列表，相对地址基于 base 解析为绝对地址
+func parseHLSPlaylist(data []byte, base *urlpkg.URL) (*hlsPlaylist, error) {
+	pl := &hlsPlaylist{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	resolve := func(ref string) (string, error) {
+		u, err := base.Parse(ref)
+		if err != nil {
+			return "", err
+		}
+		return u.String(), nil
+	}
+
+	first := true
+	var pendingDuration time.Duration
+	var pendingVariant *hlsVariant
+	seq := int64(-1)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if first {
+			if !strings.HasPrefix(line, "#EXTM3U") {
+				return nil, fmt.Errorf("不是有效的 m3u8 播放列表")
+			}
+			first = false
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bw, _ := strconv.ParseInt(attrs["BANDWIDTH"], 10, 64)
+			pendingVariant = &hlsVariant{bandwidth: bw}
+			pl.isMaster = true
+
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			v, err := strconv.ParseFloat(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"), 64)
+			if err == nil {
+				pl.targetDuration = time.Duration(v * float64(time.Second))
+			}
+
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			v, err := strconv.ParseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+			if err == nil {
+				pl.mediaSequence = v
+			}
+
+		case strings.HasPrefix(line, "#EXTINF:"):
+			v := strings.TrimPrefix(line, "#EXTINF:")
+			if comma := strings.IndexByte(v, ','); comma >= 0 {
+				v = v[:comma]
+			}
+			d, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("EXTINF 时长无效: %s", line)
+			}
+			pendingDuration = time.Duration(d * float64(time.Second))
+
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attrs := parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			if method := attrs["METHOD"]; method != "" && method != "NONE" {
+				return nil, fmt.Errorf("不支持加密的 HLS 分片: METHOD=%s", method)
+			}
+
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			return nil, fmt.Errorf("不支持 fMP4 分片的 HLS 流")
+
+		case strings.HasPrefix(line, "#EXT-X-ENDLIST"):
+			pl.endList = true
+
+		case strings.HasPrefix(line, "#"):
+			// 其他标签忽略
+
+		default:
+			uri, err := resolve(line)
+			if err != nil {
+				return nil, fmt.Errorf("解析 URI 失败: %w", err)
+			}
+			if pendingVariant != nil {
+				pendingVariant.uri = uri
+				pl.variants = append(pl.variants, *pendingVariant)
+				pendingVariant = nil
+				continue
+			}
+			if seq < 0 {
+				seq = pl.mediaSequence
+			}
+			pl.segments = append(pl.segments, hlsSegment{
+				uri:      uri,
+				duration: pendingDuration,
+				seq:      seq,
+			})
+			seq++
+			pendingDuration = 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if first {
+		return nil, fmt.Errorf("播放列表为空")
+	}
+	if pl.isMaster && len(pl.variants) == 0 {
+		return nil, fmt.Errorf("多码率播放列表中没有可用档位")
+	}
+
+	return pl, nil
+}
+
+// hlsReader HLS 拉流读取器，实现 Prober
+// 流程：拉取播放列表 -> （多码率时选择最高码率档位）-> 从直播边缘下载最新分片 -> 解复用 MPEG-TS
+// 分片读完后按 EXT-X-TARGETDURATION 的一半刷新播放列表，等待新分片
+type hlsReader struct {
+	ctx         context.Context
+	client      *http.Client
+	playlistURL string // 当前使用的媒体播放列表地址
+
+	// 分片读取统计模板：每个分片复制一份，共享同一组计数器
+	tracking *stallTrackingReader
+
+	demuxer    *tsDemuxer
+	playlist   *hlsPlaylist
+	nextSeq    int64
+	lastReload time.Time
+
+	// HLS 专属统计
+	responseTime         time.Duration      // 首次播放列表请求的响应头时间
+	trace                httpTimingTrace    // 首次播放列表请求的 DNS / TCP / TLS / 服务端处理耗时
+	tlsByHost            map[string]TLSInfo // 各域名第一次请求的对端证书和 TLS 握手信息（播放列表和分片可能在不同域名上）
+	playlistRefreshCount int                // 播放列表刷新次数（不含首次加载）
+	playlistRefreshTotal time.Duration      // 播放列表刷新总耗时
+	segmentCount         int                // 已下载分片数
+	segmentDownloadTotal time.Duration      // 分片下载总耗时
+	segmentDownloadMax   time.Duration      // 分片下载最长耗时
+	segmentDurationTotal time.Duration      // 已下载分片的媒体总时长
+	sequenceResets       int                // 媒体序号回退次数
+}
+
+// HLSStats HLS 传输统计
+type HLSStats struct {
+	PlaylistRefreshMs    float64 // 平均播放列表刷新耗时（ms）
+	SegmentDownloadMs    float64 // 平均分片下载耗时（ms）
+	SegmentDownloadMaxMs float64 // 最长分片下载耗时（ms）
+	SegmentDownloadRatio float64 // 分片下载耗时 / 分片时长
+	SegmentCount         int64   // 本次检查下载的分片数
+	TargetDurationSec    float64 // EXT-X-TARGETDURATION（秒）
+	SequenceResets       int64   // 媒体序号回退次数（打包器或编码器重启）
+}
+
+// newHLSProber 创建 HLS Prober
+func newHLSProber(playlistURL string, tracking *stallTrackingReader) Prober {
+	return &hlsReader{
+		playlistURL: playlistURL,
+		tracking:    tracking,
+		demuxer:     newTSDemuxer(),
+		tlsByHost:   make(map[string]TLSInfo),
+	}
+}
+
+// segmented 分片整块下载后再解复用，同一分片的包同时到达
+func (r *hlsReader) segmented() bool { return true }
+
+// fetch 发起 GET 请求并读取完整响应体，返回响应头耗时和总耗时
+func (r *hlsReader) fetch(rawURL string, tracked bool) (data []byte, headerTime, total time.Duration, err error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(r.ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("创建请求失败: %w", err)
+	}
+	setRequestHeader(req)
+
+	resp, err := r.client.Do(req)
+	headerTime = time.Since(start)
+	if host := req.URL.Hostname(); !r.tlsRecorded(host) {
+		r.tlsByHost[host] = httpTLSInfo(resp, err, host)
+	}
+	if err != nil {
+		if r.ctx.Err() == context.DeadlineExceeded {
+			return nil, headerTime, 0, fmt.Errorf("请求超时: %w", err)
+		}
+		return nil, headerTime, 0, fmt.Errorf("连接失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, headerTime, 0, fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
+	}
+
+	var body io.Reader = resp.Body
+	if tracked && r.tracking != nil {
+		tr := *r.tracking
+		tr.reader = resp.Body
+		tr.firstReadDone = !tr.firstReadTime.IsZero()
+		body = &tr
+	}
+
+	data, err = io.ReadAll(body)
+	total = time.Since(start)
+	if err != nil {
+		return nil, headerTime, total, fmt.Errorf("读取响应失败: %w", err)
+	}
+	return data, headerTime, total, nil
+}
+
+// loadPlaylist 拉取并解析播放列表
+func (r *hlsReader) loadPlaylist(rawURL string) (*hlsPlaylist, time.Duration, time.Duration, error) {
+	base, err := urlpkg.Parse(rawURL)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("播放列表地址无效: %w", err)
+	}
+	data, headerTime, total, err := r.fetch(rawURL, false)
+	if err != nil {
+		return nil, headerTime, total, err
+	}
+	pl, err := parseHLSPlaylist(data, base)
+	if err != nil {
+		return nil, headerTime, total, fmt.Errorf("解析播放列表失败: %w", err)
+	}
+	return pl, headerTime, total, nil
+}
+
+// Open 加载播放列表并定位到直播边缘
+func (r *hlsReader) Open(ctx context.Context) error {
+	r.client = httpClientFor(ctx)
+
+	// 只记录首次播放列表请求的各阶段耗时，之后的请求通常复用同一个连接
+	r.ctx = r.trace.withContext(ctx)
+	pl, headerTime, _, err := r.loadPlaylist(r.playlistURL)
+	r.ctx = ctx
+	r.responseTime = headerTime
+	if err != nil {
+		return err
+	}
+
+	// 多码率播放列表：选择最高码率档位
+	if pl.isMaster {
+		best := pl.variants[0]
+		for _, v := range pl.variants[1:] {
+			if v.bandwidth > best.bandwidth {
+				best = v
+			}
+		}
+		r.playlistURL = best.uri
+		if pl, _, _, err = r.loadPlaylist(r.playlistURL); err != nil {
+			return fmt.Errorf("加载媒体播放列表失败: %w", err)
+		}
+		if pl.isMaster {
+			return fmt.Errorf("媒体播放列表嵌套了多码率播放列表")
+		}
+	}
+
+	if len(pl.segments) == 0 {
+		return fmt.Errorf("播放列表中没有分片")
+	}
+
+	r.playlist = pl
+	r.lastReload = time.Now()
+	r.nextSeq = pl.liveEdgeSeq()
+	return nil
+}
+
+// liveEdgeSeq 起播分片的序号：直播从末尾往前取 hlsLiveEdgeSegments 个分片，点播从第一个分片开始
+func (pl *hlsPlaylist) liveEdgeSeq() int64 {
+	start := len(pl.segments) - hlsLiveEdgeSegments
+	if start < 0 || pl.endList {
+		start = 0
+	}
+	return pl.segments[start].seq
+}
+
+// reloadPlaylist 刷新媒体播放列表（直播）
+func (r *hlsReader) reloadPlaylist() error {
+	// 按规范：播放列表无变化时至少等待半个目标时长再刷新
+	wait := r.playlist.targetDuration / 2
+	if wait <= 0 {
+		wait = time.Second
+	}
+	if d := time.Until(r.lastReload.Add(wait)); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-r.ctx.Done():
+			return fmt.Errorf("请求超时: %w", r.ctx.Err())
+		}
+	}
+
+	pl, _, total, err := r.loadPlaylist(r.playlistURL)
+	r.lastReload = time.Now()
+	if err != nil {
+		return fmt.Errorf("刷新播放列表失败: %w", err)
+	}
+	r.playlistRefreshCount++
+	r.playlistRefreshTotal += total
+
+	if n := len(pl.segments); n > 0 {
+		switch {
+		case pl.segments[n-1].seq < r.nextSeq-1:
+			// 最新分片的序号比已下载的还小：打包器或编码器重启后序号回退，原来的 nextSeq 不会再出现，重新定位到直播边缘
+			r.sequenceResets++
+			r.nextSeq = pl.liveEdgeSeq()
+		case r.nextSeq < pl.segments[0].seq:
+			// 落后于播放列表窗口时跳到最早的可用分片
+			r.nextSeq = pl.segments[0].seq
+		}
+	}
+	r.playlist = pl
+	return nil
+}
+
+// nextSegment 返回下一个待下载的分片
+func (r *hlsReader) nextSegment() (hlsSegment, bool) {
+	for _, seg := range r.playlist.segments {
+		if seg.seq == r.nextSeq {
+			return seg, true
+		}
+	}
+	return hlsSegment{}, false
+}
+
+// downloadSegment 下载分片并送入 TS 解复用器
+func (r *hlsReader) downloadSegment(seg hlsSegment) error {
+	data, _, total, err := r.fetch(seg.uri, true)
+	if err != nil {
+		return fmt.Errorf("下载分片失败: %w", err)
+	}
+
+	r.segmentCount++
+	r.segmentDownloadTotal += total
+	if total > r.segmentDownloadMax {
+		r.segmentDownloadMax = total
+	}
+	r.segmentDurationTotal += seg.duration
+	r.nextSeq = seg.seq + 1
+
+	if err := r.demuxer.Feed(data); err != nil {
+		return fmt.Errorf("解析 TS 分片失败: %w", err)
+	}
+	return nil
+}
+
+// ReadPacket 读取下一个音视频包
+func (r *hlsReader) ReadPacket() (av.Packet, error) {
+	for {
+		if pkt, ok := r.demuxer.Next(); ok {
+			return pkt, nil
+		}
+
+		if seg, ok := r.nextSegment(); ok {
+			if err := r.downloadSegment(seg); err != nil {
+				return av.Packet{}, err
+			}
+			continue
+		}
+
+		if r.playlist.endList {
+			return av.Packet{}, io.EOF
+		}
+		if err := r.reloadPlaylist(); err != nil {
+			return av.Packet{}, err
+		}
+	}
+}
+
+// avgPlaylistRefreshMs 平均播放列表刷新耗时（ms）
+func (r *hlsReader) avgPlaylistRefreshMs() float64 {
+	if r.playlistRefreshCount == 0 {
+		return 0
+	}
+	return r.playlistRefreshTotal.Seconds() * 1000 / float64(r.playlistRefreshCount)
+}
+
+// avgSegmentDownloadMs 平均分片下载耗时（ms）
+func (r *hlsReader) avgSegmentDownloadMs() float64 {
+	if r.segmentCount == 0 {
+		return 0
+	}
+	return r.segmentDownloadTotal.Seconds() * 1000 / float64(r.segmentCount)
+}
+
+// segmentDownloadRatio 分片下载耗时 / 分片媒体时长，大于 1 表示下载速度跟不上播放速度
+func (r *hlsReader) segmentDownloadRatio() float64 {
+	if r.segmentDurationTotal <= 0 {
+		return 0
+	}
+	return r.segmentDownloadTotal.Seconds() / r.segmentDurationTotal.Seconds()
+}
+
+// TransportStats 返回传输层统计，响应时间为首次播放列表请求的响应头返回时间
+// TLS 信息取各域名中证书校验失败或最早过期的一个（见 tlsInfo）
+func (r *hlsReader) TransportStats() TransportStats {
+	stats := HLSStats{
+		PlaylistRefreshMs:    r.avgPlaylistRefreshMs(),
+		SegmentDownloadMs:    r.avgSegmentDownloadMs(),
+		SegmentDownloadMaxMs: r.segmentDownloadMax.Seconds() * 1000,
+		SegmentDownloadRatio: r.segmentDownloadRatio(),
+		SegmentCount:         int64(r.segmentCount),
+		SequenceResets:       int64(r.sequenceResets),
+	}
+	if r.playlist != nil {
+		stats.TargetDurationSec = r.playlist.targetDuration.Seconds()
+	}
+	return TransportStats{ResponseTime: r.responseTime, RemoteIP: r.trace.peerIP(), HTTP: r.trace.timing(), TLS: r.tlsInfo(), HLS: stats}
+}
+
+// tlsRecorded 是否已经记录了该域名的 TLS 信息
+func (r *hlsReader) tlsRecorded(host string) bool {
+	_, ok := r.tlsByHost[host]
+	return ok
+}
+
+// tlsInfo 各域名中最需要关注的 TLS 信息：证书校验失败的优先，其次是最早过期的证书
+func (r *hlsReader) tlsInfo() TLSInfo {
+	var worst TLSInfo
+	for _, info := range r.tlsByHost {
+		if !info.Present {
+			continue
+		}
+		switch {
+		case !worst.Present,
+			worst.Verified && !info.Verified,
+			worst.Verified == info.Verified && info.NotAfter.Before(worst.NotAfter):
+			worst = info
+		}
+	}
+	return worst
+}
+
+// Close 分片均为完整下载，没有需要关闭的连接
+func (r *hlsReader) Close() error {
+	return nil
+}
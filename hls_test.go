@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	urlpkg "net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nareix/joy5/av"
+)
+
+func TestParseHLSPlaylist(t *testing.T) {
+	base, _ := urlpkg.Parse("http://example.com/live/stream/index.m3u8?token=abc")
+
+	tests := []struct {
+		name     string
+		data     string
+		wantErr  bool
+		master   bool
+		variants []hlsVariant
+		target   time.Duration
+		segments []hlsSegment
+		endList  bool
+	}{
+		{
+			name: "多码率",
+			data: strings.Join([]string{
+				"#EXTM3U",
+				`#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.42e01e,mp4a.40.2"`,
+				"low/index.m3u8",
+				"#EXT-X-STREAM-INF:BANDWIDTH=2500000",
+				"../hd/index.m3u8?token=abc",
+				"#EXT-X-STREAM-INF:BANDWIDTH=5000000",
+				"https://cdn.example.com/uhd.m3u8",
+			}, "\n"),
+			master: true,
+			variants: []hlsVariant{
+				{uri: "http://example.com/live/stream/low/index.m3u8", bandwidth: 800000},
+				{uri: "http://example.com/live/hd/index.m3u8?token=abc", bandwidth: 2500000},
+				{uri: "https://cdn.example.com/uhd.m3u8", bandwidth: 5000000},
+			},
+		},
+		{
+			name: "直播媒体播放列表",
+			data: strings.Join([]string{
+				"#EXTM3U",
+				"#EXT-X-VERSION:3",
+				"#EXT-X-TARGETDURATION:4",
+				"#EXT-X-MEDIA-SEQUENCE:1024",
+				"#EXTINF:4.000,",
+				"seg1024.ts",
+				"#EXTINF:3.5,title",
+				"/abs/seg1025.ts",
+				"",
+				"#EXT-X-KEY:METHOD=NONE",
+				"#EXTINF:4",
+				"seg1026.ts?t=1",
+			}, "\r\n"),
+			target: 4 * time.Second,
+			segments: []hlsSegment{
+				{uri: "http://example.com/live/stream/seg1024.ts", duration: 4 * time.Second, seq: 1024},
+				{uri: "http://example.com/abs/seg1025.ts", duration: 3500 * time.Millisecond, seq: 1025},
+				{uri: "http://example.com/live/stream/seg1026.ts?t=1", duration: 4 * time.Second, seq: 1026},
+			},
+		},
+		{
+			name:   "点播无媒体序号",
+			data:   "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\na.ts\n#EXTINF:1.5,\nb.ts\n#EXT-X-ENDLIST\n",
+			target: 2 * time.Second,
+			segments: []hlsSegment{
+				{uri: "http://example.com/live/stream/a.ts", duration: 2 * time.Second, seq: 0},
+				{uri: "http://example.com/live/stream/b.ts", duration: 1500 * time.Millisecond, seq: 1},
+			},
+			endList: true,
+		},
+		{name: "不是 m3u8", data: "<html></html>", wantErr: true},
+		{name: "空内容", data: "\n\n", wantErr: true},
+		{name: "加密分片", data: "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXTINF:2,\na.ts\n", wantErr: true},
+		{name: "fMP4 分片", data: "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:2,\na.m4s\n", wantErr: true},
+		{name: "EXTINF 无效", data: "#EXTM3U\n#EXTINF:abc,\na.ts\n", wantErr: true},
+		{name: "多码率无档位", data: "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl, err := parseHLSPlaylist([]byte(tt.data), base)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("期望返回错误，得到 %+v", pl)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if pl.isMaster != tt.master || pl.targetDuration != tt.target || pl.endList != tt.endList {
+				t.Errorf("播放列表 master=%v target=%v endList=%v，期望 %v %v %v", pl.isMaster, pl.targetDuration, pl.endList, tt.master, tt.target, tt.endList)
+			}
+			if !slices.Equal(pl.variants, tt.variants) {
+				t.Errorf("档位 %+v，期望 %+v", pl.variants, tt.variants)
+			}
+			if !slices.Equal(pl.segments, tt.segments) {
+				t.Errorf("分片 %+v，期望 %+v", pl.segments, tt.segments)
+			}
+		})
+	}
+}
+
+// testHLSServer 测试用 HLS 源站：多码率播放列表 + 可替换的媒体播放列表，分片按序号生成
+type testHLSServer struct {
+	mu       sync.Mutex
+	media    string
+	requests []string
+}
+
+func (s *testHLSServer) setMedia(mediaSequence, count int) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:%d\n", mediaSequence)
+	for seq := mediaSequence; seq < mediaSequence+count; seq++ {
+		fmt.Fprintf(&b, "#EXTINF:1.000,\nseg%d.ts\n", seq)
+	}
+	s.mu.Lock()
+	s.media = b.String()
+	s.mu.Unlock()
+}
+
+func (s *testHLSServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, req.URL.Path)
+	media := s.media
+	s.mu.Unlock()
+
+	switch path := req.URL.Path; {
+	case path == "/live/master.m3u8":
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=500000\nlow/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=2000000\nhigh/index.m3u8\n")
+	case path == "/live/high/index.m3u8":
+		fmt.Fprint(w, media)
+	case strings.HasPrefix(path, "/live/high/seg"):
+		var seq int64
+		if _, err := fmt.Sscanf(path, "/live/high/seg%d.ts", &seq); err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		// 每个分片一个关键帧，PTS 为序号秒
+		tw := newTestTSWriter()
+		tw.tables([2]int{testVideoPID, tsStreamTypeH264})
+		tw.pes(testVideoPID, testPES(0xe0, seq*tsClockRate, -1, testH264AccessUnit(true, 10)))
+		w.Write(tw.buf.Bytes())
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (s *testHLSServer) segmentRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var segs []string
+	for _, path := range s.requests {
+		if strings.HasSuffix(path, ".ts") {
+			segs = append(segs, strings.TrimPrefix(path, "/live/high/"))
+		}
+	}
+	return segs
+}
+
+func TestHLSSession(t *testing.T) {
+	initHTTPClient()
+
+	srv := &testHLSServer{}
+	srv.setMedia(100, 5)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	prober := newHLSProber(ts.URL+"/live/master.m3u8", newTestTrackingReader())
+	if err := prober.Open(ctx); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer prober.Close()
+
+	readFrames := func(n int) []time.Duration {
+		t.Helper()
+		var times []time.Duration
+		for len(times) < n {
+			pkt, err := prober.ReadPacket()
+			if err != nil {
+				t.Fatalf("读取第 %d 帧: %v", len(times)+1, err)
+			}
+			if pkt.Type == av.H264 {
+				times = append(times, pkt.Time)
+			}
+		}
+		return times
+	}
+
+	// 选择最高码率档位，从直播边缘往前 3 个分片开始
+	if got, want := readFrames(3), []time.Duration{102 * time.Second, 103 * time.Second, 104 * time.Second}; !slices.Equal(got, want) {
+		t.Errorf("帧时间 %v，期望 %v", got, want)
+	}
+
+	// 打包器重启后序号从 0 开始，重新定位到直播边缘
+	srv.setMedia(0, 4)
+	if got, want := readFrames(1), []time.Duration{time.Second}; !slices.Equal(got, want) {
+		t.Errorf("序号回退后帧时间 %v，期望 %v", got, want)
+	}
+
+	if got, want := srv.segmentRequests(), []string{"seg102.ts", "seg103.ts", "seg104.ts", "seg1.ts"}; !slices.Equal(got, want) {
+		t.Errorf("分片请求 %v，期望 %v", got, want)
+	}
+	stats := prober.TransportStats().HLS
+	if stats.SequenceResets != 1 || stats.SegmentCount != 4 || stats.TargetDurationSec != 1 {
+		t.Errorf("HLS 统计 %+v，期望序号回退 1 次、4 个分片、目标时长 1 秒", stats)
+	}
+}
+
+func TestHLSTLSInfo(t *testing.T) {
+	now := time.Now()
+	r := newHLSProber("https://origin.example.com/live/index.m3u8", newTestTrackingReader()).(*hlsReader)
+	r.tlsByHost["origin.example.com"] = TLSInfo{Present: true, Verified: true, Issuer: "origin", NotAfter: now.Add(90 * 24 * time.Hour)}
+	r.tlsByHost["plain.example.com"] = TLSInfo{}
+	r.tlsByHost["cdn.example.com"] = TLSInfo{Present: true, Verified: true, Issuer: "cdn", NotAfter: now.Add(7 * 24 * time.Hour)}
+	if got := r.TransportStats().TLS; got.Issuer != "cdn" {
+		t.Errorf("TLS 信息来自 %q，期望最早过期的 cdn", got.Issuer)
+	}
+
+	// 校验失败的证书优先
+	r.tlsByHost["bad.example.com"] = TLSInfo{Present: true, Issuer: "bad", NotAfter: now.Add(365 * 24 * time.Hour)}
+	if got := r.TransportStats().TLS; got.Issuer != "bad" {
+		t.Errorf("TLS 信息来自 %q，期望校验失败的 bad", got.Issuer)
+	}
+}
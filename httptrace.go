@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// HTTPTiming HTTP 请求各阶段耗时（HTTP-FLV 的拉流请求、HLS 的首次播放列表请求）
+// 复用连接池中的连接时没有 DNS / TCP / TLS 阶段，对应耗时为 0
+type HTTPTiming struct {
+	DNSMs        float64 // DNS 解析耗时（ms）
+	TCPConnectMs float64 // TCP 连接耗时（ms）
+	TLSMs        float64 // TLS 握手耗时（ms），http:// 为 0
+	ServerMs     float64 // 服务端处理耗时（ms）：请求发送完成到收到响应第一个字节
+	Reused       bool    // 是否复用了 globalHTTPClient 连接池中的连接
+}
+
+// httpTimingTrace 通过 httptrace 记录 HTTP 请求各阶段的时间点
+// 连接在 Transport 的拨号协程中建立（请求取消后也可能继续），回调和读取都需要加锁
+type httpTimingTrace struct {
+	mu                        sync.Mutex
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+	reused                    bool
+	remoteIP                  string // 实际连接的对端 IP
+}
+
+// withContext 返回带 httptrace 的 context，用于发起需要记录耗时的请求
+func (t *httpTimingTrace) withContext(ctx context.Context) context.Context {
+	// mark 记录时间点（只记录第一次，多个地址依次或并发尝试连接时取第一次开始的时间）
+	mark := func(field *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if field.IsZero() {
+			*field = time.Now()
+		}
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { mark(&t.dnsStart) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { mark(&t.dnsDone) },
+		ConnectStart: func(string, string) { mark(&t.connectStart) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				mark(&t.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { mark(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+			t.remoteIP = connRemoteIP(info.Conn)
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() { mark(&t.firstByte) },
+	})
+}
+
+// timing 计算各阶段耗时，没有经历的阶段为 0
+func (t *httpTimingTrace) timing() HTTPTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	between := func(start, end time.Time) float64 {
+		if start.IsZero() || end.IsZero() || end.Before(start) {
+			return 0
+		}
+		return end.Sub(start).Seconds() * 1000
+	}
+	return HTTPTiming{
+		DNSMs:        between(t.dnsStart, t.dnsDone),
+		TCPConnectMs: between(t.connectStart, t.connectDone),
+		TLSMs:        between(t.tlsStart, t.tlsDone),
+		ServerMs:     between(t.wroteRequest, t.firstByte),
+		Reused:       t.reused,
+	}
+}
+
+// peerIP 返回实际连接的对端 IP，还没有拿到连接时为空
+func (t *httpTimingTrace) peerIP() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.remoteIP
+}
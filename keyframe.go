@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nareix/joy5/av"
+	"github.com/nareix/joy5/codec/h264"
+)
+
+// getFFmpegPath 获取 ffmpeg 可执行文件（从配置读取，默认在 PATH 中查找 ffmpeg）
+func getFFmpegPath() string {
+	if globalConfig != nil && globalConfig.Exporter.FFmpegPath != "" {
+		return globalConfig.Exporter.FFmpegPath
+	}
+	return "ffmpeg" // 默认值
+}
+
+// ffmpegDecodeTimeout 单次 ffmpeg 调用的超时时间
+const ffmpegDecodeTimeout = 5 * time.Second
+
+var (
+	ffmpegOnce sync.Once
+	ffmpegBin  string
+	ffmpegErr  error
+)
+
+// lookupFFmpeg 查找 ffmpeg（只查找一次），找不到时输出一次警告
+func lookupFFmpeg() (string, error) {
+	ffmpegOnce.Do(func() {
+		ffmpegBin, ffmpegErr = exec.LookPath(getFFmpegPath())
+		if ffmpegErr != nil {
+			ffmpegErr = fmt.Errorf("未找到 ffmpeg: %w", ffmpegErr)
+			GetLogger().Warn("未找到 ffmpeg，关键帧解码不可用", "路径", getFFmpegPath(), "错误", ffmpegErr)
+		}
+	})
+	return ffmpegBin, ffmpegErr
+}
+
+// Keyframe 采样期间最后一个关键帧（Annex B 格式，带参数集，可以单独解码）
+// 目前只支持 H.264 / H.265，其他编码的关键帧不保留
+type Keyframe struct {
+	Codec    string        // 视频编码（H264 / H265）
+	DTS      time.Duration // 关键帧的 DTS
+	RecvTime time.Time     // 关键帧到达的系统时间
+	Data     []byte        // Annex B 基本流：解码配置中的参数集 + 关键帧的 NALU
+}
+
+// newKeyframe 把 AVCC 格式的关键帧和解码配置中的参数集拼成可以单独解码的 Annex B 基本流
+func newKeyframe(pkt av.Packet, paramSets [][]byte, recvTime time.Time) (*Keyframe, bool) {
+	if pkt.Type != av.H264 && pkt.Type != pktH265 {
+		return nil, false
+	}
+	nalus, _ := h264.SplitNALUs(pkt.Data)
+	if len(nalus) == 0 {
+		return nil, false
+	}
+	all := make([][]byte, 0, len(paramSets)+len(nalus))
+	all = append(append(all, paramSets...), nalus...)
+	return &Keyframe{
+		Codec:    videoCodecName(pkt.Type),
+		DTS:      pkt.Time,
+		RecvTime: recvTime,
+		Data:     h264.JoinNALUsAnnexb(all),
+	}, true
+}
+
+// decoderConfigParamSets 返回 AVC / HEVC 解码配置中的参数集 NALU
+func decoderConfigParamSets(pkt av.Packet) [][]byte {
+	switch pkt.Type {
+	case av.H264DecoderConfig:
+		codec, err := h264.FromDecoderConfig(pkt.Data)
+		if err != nil {
+			return nil
+		}
+		return append(h264.Map2arr(codec.SPS), h264.Map2arr(codec.PPS)...)
+	case pktH265DecoderConfig:
+		nalus, _ := hevcDecoderConfigNALUs(pkt.Data)
+		return nalus
+	}
+	return nil
+}
+
+// ffmpegFormat 关键帧对应的 ffmpeg 输入格式
+func (k *Keyframe) ffmpegFormat() string {
+	if k.Codec == "H265" {
+		return "hevc"
+	}
+	return "h264"
+}
+
+// decodeGray 用 ffmpeg 解码关键帧并缩放为 size x size 的灰度图（每像素一个字节的亮度）
+func (k *Keyframe) decodeGray(ctx context.Context, size int) ([]byte, error) {
+	out, err := k.runFFmpeg(ctx,
+		"-vf", fmt.Sprintf("scale=%d:%d:flags=area", size, size),
+		"-pix_fmt", "gray", "-f", "rawvideo")
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != size*size {
+		return nil, fmt.Errorf("解码结果大小不符: %d 字节，期望 %d 字节", len(out), size*size)
+	}
+	return out, nil
+}
+
+// encodeJPEG 用 ffmpeg 解码关键帧并编码为 JPEG（原始分辨率）
+func (k *Keyframe) encodeJPEG(ctx context.Context) ([]byte, error) {
+	return k.runFFmpeg(ctx, "-c:v", "mjpeg", "-q:v", "3", "-f", "image2pipe")
+}
+
+// runFFmpeg 把关键帧通过标准输入交给 ffmpeg 解码一帧，输出参数由 outputArgs 指定，返回标准输出
+func (k *Keyframe) runFFmpeg(ctx context.Context, outputArgs ...string) ([]byte, error) {
+	bin, err := lookupFFmpeg()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, ffmpegDecodeTimeout)
+	defer cancel()
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-f", k.ffmpegFormat(), "-i", "pipe:0", "-frames:v", "1"}
+	args = append(append(args, outputArgs...), "pipe:1")
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Stdin = bytes.NewReader(k.Data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffmpeg 解码失败: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ffmpeg 解码失败: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/nareix/joy5/av"
+	"github.com/nareix/joy5/format/flv/flvio"
+)
+
+// getMetadataCaptureTimeField 获取 onMetaData 中携带采集时间的字段名（从配置读取，默认不读取）
+func getMetadataCaptureTimeField() string {
+	if globalConfig != nil {
+		return globalConfig.Exporter.MetadataCaptureTimeField
+	}
+	return ""
+}
+
+// LatencyStats 直播延迟估算（本次检查的值）
+type LatencyStats struct {
+	ClockDriftMsPerSec float64 // 流落后于实时的增长速率（ms/s）：正值表示 DTS 推进慢于系统时间，流越来越落后
+	E2ELatencyMs       float64 // 端到端延迟（包到达时间 - 采集时间），没有采集时间时为 0
+	CaptureTimeSource  string  // 采集时间来源（sei_misb / sei_ntp / sei_unix_ms / metadata），没有时为空
+}
+
+// latencySampler 从 SEI / onMetaData 中提取采集时间，时钟漂移由突发结束后的视频包计算（见 clockDrift）
+type latencySampler struct {
+	metadataField string // onMetaData 中携带采集时间的字段名，为空时不读取
+
+	// 最近一次采集时间及对应包的到达时间（GOP 缓存中的旧帧延迟偏大，取最新的值）
+	captureTime time.Time
+	captureRecv time.Time
+	source      string
+}
+
+// observeVideo 记录一个视频包：查找 SEI 中的采集时间
+func (l *latencySampler) observeVideo(pkt av.Packet, recvTime time.Time) {
+	if pkt.Type != av.H264 && pkt.Type != pktH265 {
+		return
+	}
+	if t, source, ok := findSEICaptureTime(pkt.Data, pkt.Type == pktH265, recvTime); ok {
+		l.captureTime, l.captureRecv, l.source = t, recvTime, source
+	}
+}
+
+// observeMetadata 从 onMetaData 的自定义字段中读取采集时间
+// 字段值支持 Unix 毫秒数、AMF 日期、十进制 Unix 毫秒字符串或 RFC 3339 字符串
+func (l *latencySampler) observeMetadata(metadata flvio.AMFMap, recvTime time.Time) {
+	if l.metadataField == "" {
+		return
+	}
+	v, ok := metadata.GetV(l.metadataField)
+	if !ok {
+		return
+	}
+
+	var t time.Time
+	switch v := v.(type) {
+	case float64:
+		t = time.UnixMilli(int64(v))
+	case time.Time:
+		t = v
+	case string:
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			t = time.UnixMilli(ms)
+		} else if parsed, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			t = parsed
+		}
+	}
+	if t.IsZero() {
+		return
+	}
+	l.captureTime, l.captureRecv, l.source = t, recvTime, captureTimeMetadata
+}
+
+// clockDrift 落后量（系统时间推进 - DTS 推进，均相对第一个样本）对到达时间的最小二乘斜率（ms/s）
+// samples 为 GOP 缓存突发结束后的视频包：突发期间落后量快速下降，计入拟合会让漂移偏负
+func clockDrift(samples []burstSample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var fit linearFit // x = 到达时间（秒），y = 落后量（ms）
+	first := samples[0]
+	for _, s := range samples {
+		wall := s.recv.Sub(first.recv)
+		lag := wall - (s.dts - first.dts)
+		fit.add(wall.Seconds(), lag.Seconds()*1000)
+	}
+	return fit.slope()
+}
+
+// stats 返回漂移速率和端到端延迟，steady 为突发结束后的视频包（见 burstTracker.steady）
+func (l *latencySampler) stats(steady []burstSample) LatencyStats {
+	stats := LatencyStats{
+		ClockDriftMsPerSec: clockDrift(steady),
+		CaptureTimeSource:  l.source,
+	}
+	if !l.captureTime.IsZero() {
+		stats.E2ELatencyMs = l.captureRecv.Sub(l.captureTime).Seconds() * 1000
+	}
+	return stats
+}
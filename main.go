@@ -45,6 +45,9 @@ func main() {
 			line := strings.ToLower(groupName) // 线路角色转为小写（source / cdn / service）
 
 			for _, sc := range streamList {
+				// 合并项目 / 线路的默认请求参数
+				sc.Request = cfg.streamRequest(projectID, groupName, sc.Request)
+
 				// 构造标签 map
 				tags := make(map[string]string)
 
@@ -66,7 +69,10 @@ func main() {
 				tags["id"] = sc.ID
 
 				// 创建 StreamChecker 并注册到 Scheduler
-				scheduler.AddStream(sc.ID, sc.URL, projectID, line, tags)
+				if err := scheduler.AddStream(sc, projectID, line, tags); err != nil {
+					log.Error("添加流失败", "项目", projectID, "线路", line, "流ID", sc.ID, "错误", err)
+					os.Exit(1)
+				}
 				totalStreams++
 
 				log.Debug("加载流配置",
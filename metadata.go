@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+
+	"github.com/nareix/joy5/format/flv/flvio"
+)
+
+// getMetadataMismatchPercent 获取声明值与实测值的偏差阈值（百分比，从配置读取，默认20%）
+func getMetadataMismatchPercent() float64 {
+	if globalConfig != nil && globalConfig.Exporter.MetadataMismatchPercent > 0 {
+		return float64(globalConfig.Exporter.MetadataMismatchPercent)
+	}
+	return 20 // 默认值
+}
+
+// StreamMetadata onMetaData 中声明的编码参数（没有 onMetaData 或字段缺失时为零值）
+type StreamMetadata struct {
+	Present           bool    // 是否收到 onMetaData
+	Encoder           string  // 编码器，例如 "obs-output module (libobs version 30.0.2)"、"Lavf60.3.100"
+	Width             int     // 声明的宽度
+	Height            int     // 声明的高度
+	Framerate         float64 // 声明的帧率
+	VideoDataRateKbps float64 // 声明的视频码率（kbps）
+	AudioDataRateKbps float64 // 声明的音频码率（kbps）
+}
+
+// parseOnMetaData 解析 onMetaData 数据包（joy5 已去掉 @setDataFrame / onMetaData，只剩 AMF0 对象）
+func parseOnMetaData(data []byte) (flvio.AMFMap, bool) {
+	vals, err := flvio.ParseAMFVals(data, false)
+	if err != nil || len(vals) == 0 {
+		return nil, false
+	}
+	metadata, ok := vals[0].(flvio.AMFMap)
+	return metadata, ok
+}
+
+// newStreamMetadata 从 onMetaData 对象中读取声明的编码参数
+func newStreamMetadata(metadata flvio.AMFMap) StreamMetadata {
+	number := func(keys ...string) float64 {
+		for _, k := range keys {
+			if v, ok := metadata.GetFloat64(k); ok && v > 0 && !math.IsInf(v, 0) {
+				return v
+			}
+		}
+		return 0
+	}
+	encoder, _ := metadata.GetString("encoder")
+	return StreamMetadata{
+		Present:           true,
+		Encoder:           encoder,
+		Width:             int(number("width")),
+		Height:            int(number("height")),
+		Framerate:         number("framerate", "fps"),
+		VideoDataRateKbps: number("videodatarate"),
+		AudioDataRateKbps: number("audiodatarate"),
+	}
+}
+
+// MetadataMismatch 声明值与实测值偏差超过阈值的字段（声明值或实测值为 0 时不比较）
+type MetadataMismatch struct {
+	Framerate    bool // 帧率：framerate vs 实测帧率
+	VideoBitrate bool // 视频码率：videodatarate vs 按视频帧负载计算的码率
+	AudioBitrate bool // 音频码率：audiodatarate vs 按音频帧负载计算的码率
+}
+
+// compareMetadata 比较声明值和实测值，percent 为允许的偏差百分比
+func compareMetadata(metadata StreamMetadata, framerate, videoBitrate, audioBitrate, percent float64) MetadataMismatch {
+	diverges := func(declared, measured float64) bool {
+		if declared <= 0 || measured <= 0 {
+			return false
+		}
+		return math.Abs(measured-declared)/declared*100 > percent
+	}
+	return MetadataMismatch{
+		Framerate:    diverges(metadata.Framerate, framerate),
+		VideoBitrate: diverges(metadata.VideoDataRateKbps*1000, videoBitrate),
+		AudioBitrate: diverges(metadata.AudioDataRateKbps*1000, audioBitrate),
+	}
+}
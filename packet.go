@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/nareix/joy5/av"
+	"github.com/nareix/joy5/codec/aac"
+	"github.com/nareix/joy5/codec/h264"
+)
+
+// joy5 的 av 包只定义了 H.264 / AAC 相关的包类型，这里扩展其他编码的包类型
+// 取值从 100 开始，避免与 joy5 的常量冲突
+const (
+	pktH265              = 100 + iota // H.265 访问单元（AVCC 长度前缀格式）
+	pktH265DecoderConfig              // HEVCDecoderConfigurationRecord（hvcC）
+	pktAV1                            // AV1 时间单元（OBU 序列）
+	pktAV1DecoderConfig               // AV1CodecConfigurationRecord（av1C）
+	pktVP9                            // VP9 帧
+	pktVP9DecoderConfig               // VPCodecConfigurationRecord（vpcC）
+)
+
+// videoCodecName 返回视频包类型对应的编码名称，非视频包返回空字符串
+func videoCodecName(pktType int) string {
+	switch pktType {
+	case av.H264:
+		return "H264"
+	case pktH265:
+		return "H265"
+	case pktAV1:
+		return "AV1"
+	case pktVP9:
+		return "VP9"
+	}
+	return ""
+}
+
+// avcDecoderConfig 由 SPS/PPS 生成 AVCDecoderConfigurationRecord（与 FLV 的 AVC sequence header 相同）
+func avcDecoderConfig(c *h264.Codec) []byte {
+	size := 7
+	for _, ps := range c.SPS {
+		size += 2 + len(ps)
+	}
+	for _, ps := range c.PPS {
+		size += 2 + len(ps)
+	}
+	b := make([]byte, size)
+	n := 0
+	c.ToConfig(b, &n)
+	return b[:n]
+}
+
+// aacDecoderConfigPacket 由音频配置生成 AACDecoderConfig 包（AudioSpecificConfig）
+func aacDecoderConfigPacket(config aac.MPEG4AudioConfig) (av.Packet, error) {
+	var buf bytes.Buffer
+	if err := aac.WriteMPEG4AudioConfig(&buf, config); err != nil {
+		return av.Packet{}, err
+	}
+	return av.Packet{
+		Type: av.AACDecoderConfig,
+		Data: buf.Bytes(),
+		AAC:  &aac.Codec{Config: config, ConfigBytes: buf.Bytes()},
+	}, nil
+}
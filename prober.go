@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	urlpkg "net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nareix/joy5/av"
+)
+
+// Prober 拉流探测器：负责建立连接、读取音视频包并提供传输层统计
+// 采样、GOP、码率和质量评估与协议无关，由 StreamChecker 统一完成
+type Prober interface {
+	// Open 建立连接并开始拉流，ctx 控制整个检查的超时
+	Open(ctx context.Context) error
+	// ReadPacket 读取下一个音视频包
+	ReadPacket() (av.Packet, error)
+	// TransportStats 返回传输层统计（采样结束后调用，Open 失败时也可调用）
+	TransportStats() TransportStats
+	// Close 关闭连接
+	Close() error
+}
+
+// TransportStats 传输层统计
+// 通用的吞吐、阻塞、TTFB 由 stallTrackingReader 统计，这里只放协议相关的部分
+type TransportStats struct {
+	ResponseTime time.Duration // 请求响应时间（各协议的含义见对应 Prober）
+	RemoteIP     string        // 实际连接的对端 IP（边缘节点），还没有建立连接时为空
+	HTTP         HTTPTiming    // HTTP 请求各阶段耗时（仅 HTTP-FLV / HLS）
+	TLS          TLSInfo       // 对端证书和 TLS 握手信息（仅 https:// / rtmps://）
+
+	HLS  HLSStats  // 仅 HLS
+	RTMP RTMPStats // 仅 RTMP
+	RTSP RTSPStats // 仅 RTSP
+}
+
+// segmentedProber 按分片整块下载后再解复用的 Prober（HLS）
+// 同一分片的包在同一时刻交给采样器，包的到达时间不反映服务端的发送节奏，不做初始突发和到达间隔分析
+type segmentedProber interface {
+	segmented() bool
+}
+
+// avSyncProber 各轨道时间戳不一定在同一时间轴上的 Prober（RTSP）
+// Open 之后 avAligned 返回 false 时不计算音画同步
+type avSyncProber interface {
+	avAligned() bool
+}
+
+// ptsProber 视频包只有 PTS、没有 DTS 的 Prober（RTSP 的 RTP 时间戳）
+// 带 B 帧时按解码顺序 PTS 会小幅回退，不能当作 DTS 统计回退和 DTS 间隔
+type ptsProber interface {
+	videoPTS() bool
+}
+
+// ProberFactory 创建 Prober
+// tracking 为读取统计模板，Prober 需要让所有网络读取经过它（复制一份并设置 reader）
+type ProberFactory func(rawURL string, tracking *stallTrackingReader) Prober
+
+// proberSpec 注册的协议
+type proberSpec struct {
+	name       string
+	schemes    []string // 匹配的 URL scheme（小写）
+	extensions []string // 匹配的路径扩展名（小写，为空表示该 scheme 的默认协议）
+	factory    ProberFactory
+}
+
+var (
+	proberMu    sync.RWMutex
+	proberSpecs = make(map[string]*proberSpec)
+)
+
+// RegisterProber 注册协议
+// schemes 用于按 URL 自动识别协议；extensions 非空时还需路径扩展名匹配（例如 http 下的 .m3u8）
+func RegisterProber(name string, schemes, extensions []string, factory ProberFactory) {
+	proberMu.Lock()
+	defer proberMu.Unlock()
+
+	proberSpecs[name] = &proberSpec{
+		name:       name,
+		schemes:    schemes,
+		extensions: extensions,
+		factory:    factory,
+	}
+}
+
+func init() {
+	RegisterProber("flv", []string{"http", "https"}, nil, newFLVProber)
+	RegisterProber("hls", []string{"http", "https"}, []string{".m3u8"}, newHLSProber)
+	RegisterProber("rtmp", []string{"rtmp", "rtmps"}, nil, newRTMPProber)
+	RegisterProber("rtsp", []string{"rtsp"}, nil, newRTSPProber)
+}
+
+// registeredProtocols 返回已注册的协议名（排序后，用于错误提示）
+func registeredProtocols() []string {
+	names := make([]string, 0, len(proberSpecs))
+	for name := range proberSpecs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// resolveProtocol 确定流使用的协议
+// protocol 非空时直接使用（需已注册）；否则按 URL scheme 和路径扩展名识别
+func resolveProtocol(protocol, rawURL string) (string, error) {
+	proberMu.RLock()
+	defer proberMu.RUnlock()
+
+	if protocol != "" {
+		protocol = strings.ToLower(protocol)
+		if _, ok := proberSpecs[protocol]; !ok {
+			return "", fmt.Errorf("未知协议: %s（支持: %s）", protocol, strings.Join(registeredProtocols(), ", "))
+		}
+		return protocol, nil
+	}
+
+	u, err := urlpkg.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("流地址无效: %w", err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	path := strings.ToLower(u.Path)
+
+	var fallback string
+	for _, name := range registeredProtocols() {
+		spec := proberSpecs[name]
+		if !slices.Contains(spec.schemes, scheme) {
+			continue
+		}
+		if len(spec.extensions) == 0 {
+			fallback = name
+			continue
+		}
+		for _, ext := range spec.extensions {
+			if strings.HasSuffix(path, ext) {
+				return name, nil
+			}
+		}
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("无法根据地址识别协议: %s，请在配置中指定 protocol", rawURL)
+	}
+	return fallback, nil
+}
+
+// newProber 按协议名创建 Prober
+func newProber(protocol, rawURL string, tracking *stallTrackingReader) (Prober, error) {
+	proberMu.RLock()
+	spec, ok := proberSpecs[protocol]
+	proberMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未知协议: %s", protocol)
+	}
+	return spec.factory(rawURL, tracking), nil
+}
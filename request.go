@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// 请求参数中从外部读取密钥的前缀
+const (
+	secretEnvPrefix  = "env:"  // env:变量名，读取环境变量（未设置时报错）
+	secretFilePrefix = "file:" // file:文件路径，读取文件内容（去掉首尾空白）
+)
+
+// resolveSecret 读取 env: / file: 形式的值，其他值原样返回
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretEnvPrefix):
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("环境变量 %s 未设置", name)
+		}
+		return v, nil
+	case strings.HasPrefix(value, secretFilePrefix):
+		data, err := os.ReadFile(strings.TrimPrefix(value, secretFilePrefix))
+		if err != nil {
+			return "", fmt.Errorf("读取密钥文件失败: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return value, nil
+}
+
+// streamRequest 合并请求参数：项目默认值 < 项目/线路默认值 < 流配置（项目和线路不区分大小写）
+func (c *Config) streamRequest(project, line string, stream RequestConfig) RequestConfig {
+	var merged RequestConfig
+	for _, key := range []string{project, project + "/" + line} {
+		for name, defaults := range c.Requests {
+			if strings.EqualFold(name, key) {
+				merged = merged.merge(defaults)
+			}
+		}
+	}
+	return merged.merge(stream)
+}
+
+// merge 用 override 中设置了的字段覆盖当前值（请求头和 Cookie 按名称逐个覆盖）
+func (r RequestConfig) merge(override RequestConfig) RequestConfig {
+	merged := RequestConfig{
+		UserAgent: r.UserAgent,
+		Referer:   r.Referer,
+		Headers:   make(map[string]string, len(r.Headers)+len(override.Headers)),
+		Cookies:   make(map[string]string, len(r.Cookies)+len(override.Cookies)),
+	}
+	if override.UserAgent != "" {
+		merged.UserAgent = override.UserAgent
+	}
+	if override.Referer != "" {
+		merged.Referer = override.Referer
+	}
+	for _, headers := range []map[string]string{r.Headers, override.Headers} {
+		for name, value := range headers {
+			merged.Headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	maps.Copy(merged.Cookies, r.Cookies)
+	maps.Copy(merged.Cookies, override.Cookies)
+	return merged
+}
+
+// header 生成请求头（读取 env: / file: 形式的密钥），没有配置时返回 nil
+// user_agent / referer 优先于 headers 中的同名请求头，cookies 追加到 headers 中的 Cookie 之后
+func (r RequestConfig) header() (http.Header, error) {
+	h := make(http.Header)
+	for name, value := range r.Headers {
+		v, err := resolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("请求头 %s: %w", name, err)
+		}
+		h.Set(name, v)
+	}
+	for name, value := range map[string]string{"User-Agent": r.UserAgent, "Referer": r.Referer} {
+		if value == "" {
+			continue
+		}
+		v, err := resolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		h.Set(name, v)
+	}
+	if len(r.Cookies) > 0 {
+		cookies := make([]string, 0, len(r.Cookies)+1)
+		if existing := h.Get("Cookie"); existing != "" {
+			cookies = append(cookies, existing)
+		}
+		for _, name := range slices.Sorted(maps.Keys(r.Cookies)) {
+			v, err := resolveSecret(r.Cookies[name])
+			if err != nil {
+				return nil, fmt.Errorf("Cookie %s: %w", name, err)
+			}
+			cookies = append(cookies, name+"="+v)
+		}
+		h.Set("Cookie", strings.Join(cookies, "; "))
+	}
+	if len(h) == 0 {
+		return nil, nil
+	}
+	return h, nil
+}
+
+type requestHeaderKey struct{}
+
+// withRequestHeader 返回带拉流请求头的 context，Prober 发起请求时通过 setRequestHeader 设置
+func withRequestHeader(ctx context.Context, h http.Header) context.Context {
+	if len(h) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, requestHeaderKey{}, h)
+}
+
+// requestHeaderFrom 返回 context 中的拉流请求头，没有配置时为 nil
+func requestHeaderFrom(ctx context.Context) http.Header {
+	h, _ := ctx.Value(requestHeaderKey{}).(http.Header)
+	return h
+}
+
+// setRequestHeader 把 context 中的拉流请求头设置到 HTTP 请求上
+func setRequestHeader(req *http.Request) {
+	for name, values := range requestHeaderFrom(req.Context()) {
+		req.Header[name] = slices.Clone(values)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	urlpkg "net/url"
+	"strings"
+	"time"
+
+	"github.com/nareix/joy5/av"
+	"github.com/nareix/joy5/format/flv/flvio"
+	"github.com/nareix/joy5/format/rtmp"
+)
+
+// rtmpBufSize RTMP 连接读写缓冲大小
+const rtmpBufSize = 64 * 1024
+
+// RTMP 命令消息类型和 connect 的事务 ID（joy5 发出的 connect 固定为 1）
+const (
+	rtmpMsgCommandAMF3 = 17
+	rtmpMsgCommandAMF0 = 20
+	rtmpConnectTransID = 1
+)
+
+// rtmpReadWriter 为 joy5 的 rtmp.Conn 提供带缓冲的读写
+type rtmpReadWriter struct {
+	*bufio.Reader
+	*bufio.Writer
+}
+
+// isConnectResult 消息是否为 connect 命令的响应（事务 ID 为 1 的 _result / _error）
+func isConnectResult(msgtypeid uint8, data []byte) bool {
+	if msgtypeid != rtmpMsgCommandAMF0 && msgtypeid != rtmpMsgCommandAMF3 {
+		return false
+	}
+	vals, err := flvio.ParseAMFVals(data, msgtypeid == rtmpMsgCommandAMF3)
+	if err != nil || len(vals) < 2 {
+		return false
+	}
+	name, _ := vals[0].(string)
+	transID, _ := vals[1].(float64)
+	return (name == "_result" || name == "_error") && transID == rtmpConnectTransID
+}
+
+// rtmpReader RTMP 拉流读取器，实现 Prober
+type rtmpReader struct {
+	url      string
+	tracking *stallTrackingReader
+
+	conn *rtmp.Conn
+	nc   net.Conn
+
+	firstReadTime *time.Time // 第一个媒体包到达时间（RTMP 的 TTFB 以首个媒体包为准）
+
+	responseTime time.Duration // 从发起连接到收到 play 的 onStatus
+
+	// 建连各阶段耗时
+	tcpConnect time.Duration // TCP 连接（rtmps 含 TLS 握手）
+	tls        TLSInfo       // 对端证书和 TLS 握手信息（仅 rtmps）
+	handshake  time.Duration // RTMP 握手（C0/C1/C2 - S0/S1/S2）
+	connect    time.Duration // 握手完成到收到 connect 的 _result
+	play       time.Duration // 收到 connect 的 _result 后 createStream + play 到收到 onStatus
+}
+
+// RTMPStats RTMP 建连各阶段耗时
+type RTMPStats struct {
+	TCPConnectMs float64 // TCP 连接耗时（ms，rtmps 含 TLS 握手）
+	HandshakeMs  float64 // RTMP 握手耗时（ms）
+	ConnectMs    float64 // connect 命令耗时（ms）
+	PlayMs       float64 // createStream + play 耗时（ms）
+}
+
+// newRTMPProber 创建 RTMP Prober
+// tracking 为读取统计模板，RTMP 连接上的所有读取都会计入吞吐与阻塞统计
+func newRTMPProber(rawURL string, tracking *stallTrackingReader) Prober {
+	return &rtmpReader{
+		url:           rawURL,
+		tracking:      tracking,
+		firstReadTime: tracking.firstReadTime,
+	}
+}
+
+// Open 建立 RTMP 连接并完成 play，各阶段耗时记录在 rtmpReader 中
+func (r *rtmpReader) Open(ctx context.Context) error {
+	u, err := urlpkg.Parse(r.url)
+	if err != nil {
+		return fmt.Errorf("RTMP 地址无效: %w", err)
+	}
+	host := rtmp.UrlGetHost(u)
+
+	dialStart := time.Now()
+	nc, err := dialStream(ctx, "tcp", host)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("请求超时: %w", err)
+		}
+		return fmt.Errorf("连接失败: %w", err)
+	}
+	if strings.EqualFold(u.Scheme, "rtmps") {
+		tlsConn := tls.Client(nc, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			r.tls = tlsInfoFromError(err, u.Hostname())
+			nc.Close()
+			return fmt.Errorf("TLS 握手失败: %w", err)
+		}
+		state := tlsConn.ConnectionState()
+		r.tls = newTLSInfo(&state, u.Hostname())
+		nc = tlsConn
+	}
+	r.tcpConnect = time.Since(dialStart)
+	r.nc = nc
+
+	// 整个会话受 context 超时控制
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+
+	// RTMP 的 TTFB 以第一个媒体包为准，握手/命令阶段的读取只计入吞吐和阻塞
+	tr := *r.tracking
+	tr.reader = nc
+	tr.firstReadDone = true
+
+	var handshakeDone time.Time
+	var connectDone time.Time
+	rw := &rtmpReadWriter{
+		Reader: bufio.NewReaderSize(&tr, rtmpBufSize),
+		Writer: bufio.NewWriterSize(nc, rtmpBufSize),
+	}
+
+	conn := rtmp.NewConn(rw)
+	conn.URL = u
+	conn.LogStageEvent = func(event string, _ string) {
+		if event == "RtmpStageHandshakeDone" {
+			handshakeDone = time.Now()
+		}
+	}
+	// 按收到的消息切分阶段：connect 的 _result 到达即 connect 完成
+	// （服务端在此之前发来的 SetChunkSize / WindowAckSize / ping 等控制消息不影响判断）
+	// 只观察消息，不处理，交回 joy5 继续解析
+	conn.HandleEvent = func(msgtypeid uint8, msgdata []byte) (bool, error) {
+		if connectDone.IsZero() && isConnectResult(msgtypeid, msgdata) {
+			connectDone = time.Now()
+		}
+		return false, nil
+	}
+
+	if err := conn.Prepare(rtmp.StageGotPublishOrPlayCommand, rtmp.PrepareReading); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("请求超时: %w", err)
+		}
+		if handshakeDone.IsZero() {
+			return fmt.Errorf("RTMP 握手失败: %w", err)
+		}
+		return fmt.Errorf("RTMP 播放失败: %w", err)
+	}
+	playDone := time.Now()
+	r.responseTime = playDone.Sub(dialStart)
+	conn.HandleEvent = nil
+
+	r.handshake = handshakeDone.Sub(dialStart) - r.tcpConnect
+	if connectDone.IsZero() {
+		// 没有收到 connect 的响应（不应出现），无法拆分，全部计入 connect
+		connectDone = playDone
+	}
+	r.connect = connectDone.Sub(handshakeDone)
+	r.play = playDone.Sub(connectDone)
+	r.conn = conn
+
+	return nil
+}
+
+// ReadPacket 读取下一个音视频包
+func (r *rtmpReader) ReadPacket() (av.Packet, error) {
+	pkt, err := readFLVPacket(r.conn.ReadTag)
+	if err == nil && r.firstReadTime.IsZero() {
+		*r.firstReadTime = time.Now()
+	}
+	return pkt, err
+}
+
+// TransportStats 返回传输层统计
+func (r *rtmpReader) TransportStats() TransportStats {
+	return TransportStats{
+		ResponseTime: r.responseTime,
+		RemoteIP:     connRemoteIP(r.nc),
+		TLS:          r.tls,
+		RTMP: RTMPStats{
+			TCPConnectMs: r.tcpConnect.Seconds() * 1000,
+			HandshakeMs:  r.handshake.Seconds() * 1000,
+			ConnectMs:    r.connect.Seconds() * 1000,
+			PlayMs:       r.play.Seconds() * 1000,
+		},
+	}
+}
+
+// Close 关闭底层连接
+func (r *rtmpReader) Close() error {
+	if r.nc == nil {
+		return nil
+	}
+	return r.nc.Close()
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nareix/joy5/format/flv/flvio"
+)
+
+// testRTMPConn 测试用 RTMP 服务端连接（简单握手，读写完整消息）
+type testRTMPConn struct {
+	br          *bufio.Reader
+	nc          net.Conn
+	readChunk   int
+	writeChunk  int
+	readHeaders map[uint32]*testRTMPHeader
+}
+
+type testRTMPHeader struct {
+	typeID   byte
+	length   int
+	streamID uint32
+	data     []byte
+}
+
+func newTestRTMPConn(nc net.Conn) *testRTMPConn {
+	return &testRTMPConn{
+		br:          bufio.NewReader(nc),
+		nc:          nc,
+		readChunk:   128,
+		writeChunk:  128,
+		readHeaders: make(map[uint32]*testRTMPHeader),
+	}
+}
+
+// handshake 简单握手：S1 不带摘要，客户端回退为复制 S1 作为 C2
+func (c *testRTMPConn) handshake() error {
+	c0c1 := make([]byte, 1537)
+	if _, err := io.ReadFull(c.br, c0c1); err != nil {
+		return err
+	}
+	s := make([]byte, 1+1536*2)
+	s[0] = 3
+	copy(s[1+1536:], c0c1[1:])
+	if _, err := c.nc.Write(s); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(c.br, make([]byte, 1536))
+	return err
+}
+
+// readMsg 读取一个完整消息（只支持单字节 csid）
+func (c *testRTMPConn) readMsg() (byte, []byte, error) {
+	for {
+		b, err := c.br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		fmtType, csid := b>>6, uint32(b&0x3f)
+		h := c.readHeaders[csid]
+		if h == nil {
+			h = &testRTMPHeader{}
+			c.readHeaders[csid] = h
+		}
+		var hdr []byte
+		switch fmtType {
+		case 0:
+			hdr = make([]byte, 11)
+		case 1:
+			hdr = make([]byte, 7)
+		case 2:
+			hdr = make([]byte, 3)
+		}
+		if _, err := io.ReadFull(c.br, hdr); err != nil {
+			return 0, nil, err
+		}
+		if fmtType <= 1 {
+			h.length = int(hdr[3])<<16 | int(hdr[4])<<8 | int(hdr[5])
+			h.typeID = hdr[6]
+		}
+		if fmtType == 0 {
+			h.streamID = binary.LittleEndian.Uint32(hdr[7:11])
+		}
+		if len(hdr) >= 3 && hdr[0] == 0xff && hdr[1] == 0xff && hdr[2] == 0xff {
+			if _, err := io.ReadFull(c.br, make([]byte, 4)); err != nil {
+				return 0, nil, err
+			}
+		}
+		n := min(c.readChunk, h.length-len(h.data))
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(c.br, chunk); err != nil {
+			return 0, nil, err
+		}
+		h.data = append(h.data, chunk...)
+		if len(h.data) < h.length {
+			continue
+		}
+		data := h.data
+		h.data = nil
+		if h.typeID == 1 {
+			c.readChunk = int(binary.BigEndian.Uint32(data))
+		}
+		return h.typeID, data, nil
+	}
+}
+
+// readCommand 读取消息直到收到 AMF0 命令，返回命令名
+func (c *testRTMPConn) readCommand() (string, error) {
+	for {
+		typeID, data, err := c.readMsg()
+		if err != nil {
+			return "", err
+		}
+		if typeID != rtmpMsgCommandAMF0 {
+			continue
+		}
+		vals, err := flvio.ParseAMFVals(data, false)
+		if err != nil || len(vals) == 0 {
+			continue
+		}
+		if name, ok := vals[0].(string); ok {
+			return name, nil
+		}
+	}
+}
+
+// writeMsg 按当前块大小写出一个消息
+func (c *testRTMPConn) writeMsg(csid byte, typeID byte, streamID uint32, data []byte) error {
+	var buf []byte
+	hdr := make([]byte, 12)
+	hdr[0] = csid
+	hdr[4], hdr[5], hdr[6] = byte(len(data)>>16), byte(len(data)>>8), byte(len(data))
+	hdr[7] = typeID
+	binary.LittleEndian.PutUint32(hdr[8:12], streamID)
+	buf = append(buf, hdr...)
+	for i := 0; i < len(data); i += c.writeChunk {
+		if i > 0 {
+			buf = append(buf, 0xc0|csid)
+		}
+		buf = append(buf, data[i:min(i+c.writeChunk, len(data))]...)
+	}
+	_, err := c.nc.Write(buf)
+	return err
+}
+
+func (c *testRTMPConn) writeCommand(streamID uint32, args ...interface{}) error {
+	return c.writeMsg(3, rtmpMsgCommandAMF0, streamID, flvio.FillAMF0ValsMalloc(args))
+}
+
+func (c *testRTMPConn) writeControl(typeID byte, payload ...byte) error {
+	return c.writeMsg(2, typeID, 0, payload)
+}
+
+// TestRTMPConnectPlaySplit 服务端在 connect 的 _result 之前发来控制消息时，
+// connect / play 阶段仍按 _result 和 onStatus 的到达时间切分
+func TestRTMPConnectPlaySplit(t *testing.T) {
+	const (
+		connectDelay = 200 * time.Millisecond
+		playDelay    = 100 * time.Millisecond
+	)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer nc.Close()
+		serverErr <- func() error {
+			c := newTestRTMPConn(nc)
+			if err := c.handshake(); err != nil {
+				return err
+			}
+			if name, err := c.readCommand(); err != nil || name != "connect" {
+				return fmt.Errorf("期望 connect，收到 %q: %v", name, err)
+			}
+
+			// connect 的响应之前先发控制消息：joy5 处理这些消息后都会刷新写缓冲（ping 还会回复）
+			controls := []struct {
+				typeID  byte
+				payload []byte
+			}{
+				{5, []byte{0, 0x26, 0x25, 0xa0}},    // WindowAckSize
+				{6, []byte{0, 0x26, 0x25, 0xa0, 2}}, // SetPeerBandwidth
+				{1, []byte{0, 0, 0x10, 0}},          // SetChunkSize 4096
+				{4, []byte{0, 0, 0, 0, 0, 0}},       // StreamBegin
+				{4, []byte{0, 6, 0, 0, 0, 1}},       // PingRequest
+				{5, []byte{0, 0x26, 0x25, 0xa0}},    // WindowAckSize
+				{4, []byte{0, 6, 0, 0, 0, 2}},       // PingRequest
+				{1, []byte{0, 0, 0x10, 0}},          // SetChunkSize 4096
+				{4, []byte{0, 6, 0, 0, 0, 3}},       // PingRequest
+				{6, []byte{0, 0x26, 0x25, 0xa0, 2}}, // SetPeerBandwidth
+			}
+			for _, ctrl := range controls {
+				if err := c.writeControl(ctrl.typeID, ctrl.payload...); err != nil {
+					return err
+				}
+				if ctrl.typeID == 1 {
+					c.writeChunk = int(binary.BigEndian.Uint32(ctrl.payload))
+				}
+			}
+
+			time.Sleep(connectDelay)
+			if err := c.writeCommand(0, "_result", 1,
+				flvio.AMFMap{{K: "fmsVer", V: "FMS/3,0,1,123"}},
+				flvio.AMFMap{{K: "level", V: "status"}, {K: "code", V: "NetConnection.Connect.Success"}},
+			); err != nil {
+				return err
+			}
+
+			// 收到 connect 的响应后客户端才发出 createStream，收到 createStream 的响应后发出 play
+			if name, err := c.readCommand(); err != nil || name != "createStream" {
+				return fmt.Errorf("期望 createStream，收到 %q: %v", name, err)
+			}
+			if err := c.writeCommand(0, "_result", 2, nil, 1); err != nil {
+				return err
+			}
+			if name, err := c.readCommand(); err != nil || name != "play" {
+				return fmt.Errorf("期望 play，收到 %q: %v", name, err)
+			}
+			time.Sleep(playDelay)
+			if err := c.writeCommand(1, "onStatus", 0, nil,
+				flvio.AMFMap{{K: "level", V: "status"}, {K: "code", V: "NetStream.Play.Start"}},
+			); err != nil {
+				return err
+			}
+			// 等待客户端关闭
+			io.Copy(io.Discard, c.br)
+			return nil
+		}()
+	}()
+
+	prober := newRTMPProber("rtmp://"+ln.Addr().String()+"/live/test", newTestTrackingReader())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := prober.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stats := prober.TransportStats().RTMP
+	prober.Close()
+	if err := <-serverErr; err != nil {
+		t.Fatalf("服务端: %v", err)
+	}
+
+	connectMs := float64(connectDelay.Milliseconds())
+	playMs := float64(playDelay.Milliseconds())
+	if stats.ConnectMs < connectMs-10 || stats.ConnectMs > connectMs+150 {
+		t.Errorf("ConnectMs = %.1f，期望约 %.0f", stats.ConnectMs, connectMs)
+	}
+	if stats.PlayMs < playMs-10 || stats.PlayMs > playMs+150 {
+		t.Errorf("PlayMs = %.1f，期望约 %.0f", stats.PlayMs, playMs)
+	}
+	if stats.HandshakeMs <= 0 {
+		t.Errorf("HandshakeMs = %.1f，期望大于 0", stats.HandshakeMs)
+	}
+}
@@ -0,0 +1,1094 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	urlpkg "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nareix/joy5/av"
+	"github.com/nareix/joy5/codec/aac"
+	"github.com/nareix/joy5/codec/h264"
+)
+
+// rtspUserAgent RTSP 请求的 User-Agent
+const rtspUserAgent = "video-exporter"
+
+// rtspMaxFrameSize 单个视频帧组装的最大字节数，防止异常流占满内存
+const rtspMaxFrameSize = 8 * 1024 * 1024
+
+// rtpResyncPackets 连续这么多个包的序号都落后于当前序号时，认为发送端序号发生了跳变而不是乱序，重新同步
+const rtpResyncPackets = 8
+
+// rtspTrack SDP 中的一路媒体及其 RTP 接收状态
+type rtspTrack struct {
+	media       string // video / audio
+	codec       string // rtpmap 中的编码名（大写），例如 H264 / H265 / MPEG4-GENERIC / PCMA
+	payloadType int
+	clockRate   int
+	channels    int
+	control     string
+	fmtp        map[string]string
+
+	channel int // TCP interleaved RTP 通道号（RTCP 为 channel+1）
+
+	// RTP 序号统计
+	hasSeq    bool
+	lastSeq   uint16
+	ssrc      uint32
+	staleRun  int   // 连续落后于 lastSeq 的包数
+	received  int64 // 收到的 RTP 包数
+	lost      int64 // 序号缺口累计丢失的包数
+	gaps      int64 // 序号缺口次数（含 SSRC 变化、序号跳变后的重新同步）
+	reordered int64 // 乱序/重复包数
+
+	// RTP 时间戳展开（相对 RTP-Info 中的 rtptime，没有时相对本轨道第一个包）
+	hasTS   bool
+	aligned bool // PLAY 响应的 RTP-Info 中带有本轨道的 rtptime
+	firstTS uint32
+	lastTS  uint32
+	tsBase  int64
+
+	// 视频帧组装
+	frameTS    uint32
+	frameNALUs [][]byte
+	frameSize  int
+	fuBuf      []byte
+	fuActive   bool
+	h264Codec  *h264.Codec
+	h264Config bool
+
+	// AAC（mpeg4-generic）参数
+	aacConfig        *aac.MPEG4AudioConfig
+	sizeLength       int
+	indexLength      int
+	indexDeltaLength int
+}
+
+// supported 是否支持解析该轨道
+func (t *rtspTrack) supported() bool {
+	switch t.codec {
+	case "H264", "H265":
+		return t.media == "video"
+	case "MPEG4-GENERIC":
+		return t.media == "audio"
+	}
+	return false
+}
+
+// parseSDP 解析 SDP，返回媒体轨道列表和会话级 control 属性
+func parseSDP(data []byte) (tracks []*rtspTrack, sessionControl string) {
+	var cur *rtspTrack
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+		value := line[2:]
+
+		switch line[0] {
+		case 'm':
+			// m=video 0 RTP/AVP 96
+			fields := strings.Fields(value)
+			cur = &rtspTrack{fmtp: map[string]string{}, payloadType: -1}
+			if len(fields) > 0 {
+				cur.media = fields[0]
+			}
+			if len(fields) > 3 {
+				cur.payloadType, _ = strconv.Atoi(fields[3])
+			}
+			// 静态载荷类型（无 rtpmap 时使用）
+			switch cur.payloadType {
+			case 0:
+				cur.codec, cur.clockRate = "PCMU", 8000
+			case 8:
+				cur.codec, cur.clockRate = "PCMA", 8000
+			}
+			tracks = append(tracks, cur)
+
+		case 'a':
+			key, attr, _ := strings.Cut(value, ":")
+			if cur == nil {
+				if key == "control" {
+					sessionControl = attr
+				}
+				continue
+			}
+			switch key {
+			case "control":
+				cur.control = attr
+			case "rtpmap":
+				// a=rtpmap:96 H264/90000 或 a=rtpmap:97 MPEG4-GENERIC/44100/2
+				ptStr, enc, _ := strings.Cut(attr, " ")
+				if pt, err := strconv.Atoi(ptStr); err != nil || pt != cur.payloadType {
+					continue
+				}
+				parts := strings.Split(strings.TrimSpace(enc), "/")
+				cur.codec = strings.ToUpper(parts[0])
+				if len(parts) > 1 {
+					cur.clockRate, _ = strconv.Atoi(parts[1])
+				}
+				if len(parts) > 2 {
+					cur.channels, _ = strconv.Atoi(parts[2])
+				}
+			case "fmtp":
+				// a=fmtp:96 packetization-mode=1;profile-level-id=64001F;sprop-parameter-sets=...
+				ptStr, params, _ := strings.Cut(attr, " ")
+				if pt, err := strconv.Atoi(ptStr); err != nil || pt != cur.payloadType {
+					continue
+				}
+				for _, kv := range strings.Split(params, ";") {
+					k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+					if k != "" {
+						cur.fmtp[strings.ToLower(k)] = v
+					}
+				}
+			}
+		}
+	}
+	return tracks, sessionControl
+}
+
+// rtspResponse RTSP 响应
+type rtspResponse struct {
+	statusCode int
+	status     string
+	header     textproto.MIMEHeader
+	body       []byte
+}
+
+// rtspReader RTSP 拉流读取器，实现 Prober
+// 流程：OPTIONS -> DESCRIBE（解析 SDP）-> SETUP（RTP over TCP interleaved）-> PLAY -> 读取 RTP 并解包
+type rtspReader struct {
+	rawURL   string
+	tracking *stallTrackingReader
+
+	nc      net.Conn
+	br      *bufio.Reader
+	url     *urlpkg.URL
+	baseURL string
+
+	cseq          int
+	session       string
+	header        http.Header // 额外请求头（request 配置），User-Agent 替换默认值
+	authorization func(method, uri string) string
+
+	tracks    []*rtspTrack
+	byChannel map[int]*rtspTrack
+	pending   []av.Packet
+
+	firstReadTime *time.Time    // 第一个 RTP 媒体包到达时间
+	responseTime  time.Duration // 从发起连接到收到 PLAY 的响应
+	buf           []byte
+}
+
+// RTSPStats RTSP 传输统计
+type RTSPStats struct {
+	Packets     int64    // 收到的 RTP 包数
+	LostPackets int64    // 按序号缺口统计的丢包数
+	SeqGaps     int64    // 序号缺口次数
+	LossRatio   float64  // 丢包率（0~1）
+	Reordered   int64    // 乱序/重复包数
+	Info        RTSPInfo // SDP 中声明的编码信息
+}
+
+// newRTSPProber 创建 RTSP Prober
+func newRTSPProber(rawURL string, tracking *stallTrackingReader) Prober {
+	return &rtspReader{
+		rawURL:        rawURL,
+		tracking:      tracking,
+		byChannel:     make(map[int]*rtspTrack),
+		firstReadTime: tracking.firstReadTime,
+	}
+}
+
+// Open 建立 RTSP 会话并开始 PLAY
+func (r *rtspReader) Open(ctx context.Context) error {
+	u, err := urlpkg.Parse(r.rawURL)
+	if err != nil {
+		return fmt.Errorf("RTSP 地址无效: %w", err)
+	}
+	r.header = requestHeaderFrom(ctx)
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "554")
+	}
+
+	dialStart := time.Now()
+	nc, err := dialStream(ctx, "tcp", host)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("请求超时: %w", err)
+		}
+		return fmt.Errorf("连接失败: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	r.nc = nc
+
+	// 请求地址中不携带用户名密码
+	reqURL := *u
+	reqURL.User = nil
+	r.url = &reqURL
+	r.baseURL = reqURL.String()
+
+	// RTSP 的 TTFB 以第一个 RTP 媒体包为准，信令阶段的读取只计入吞吐和阻塞
+	tr := *r.tracking
+	tr.reader = nc
+	tr.firstReadDone = true
+	r.br = bufio.NewReaderSize(&tr, 64*1024)
+
+	if err := r.setup(u.User); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("请求超时: %w", err)
+		}
+		return err
+	}
+	r.responseTime = time.Since(dialStart)
+	return nil
+}
+
+// setup 完成 OPTIONS / DESCRIBE / SETUP / PLAY
+func (r *rtspReader) setup(user *urlpkg.Userinfo) error {
+	// 只检查 OPTIONS 是否有响应，不关心状态码（部分摄像头不实现 OPTIONS）
+	if _, err := r.request("OPTIONS", r.url.String(), nil); err != nil {
+		return fmt.Errorf("RTSP OPTIONS 失败: %w", err)
+	}
+
+	describeHeaders := map[string]string{"Accept": "application/sdp"}
+	resp, err := r.request("DESCRIBE", r.url.String(), describeHeaders)
+	if err != nil {
+		return fmt.Errorf("RTSP DESCRIBE 失败: %w", err)
+	}
+	if resp.statusCode == 401 && user != nil {
+		if err := r.setAuth(user, resp.header.Get("WWW-Authenticate")); err != nil {
+			return err
+		}
+		if resp, err = r.request("DESCRIBE", r.url.String(), describeHeaders); err != nil {
+			return fmt.Errorf("RTSP DESCRIBE 失败: %w", err)
+		}
+	}
+	if resp.statusCode != 200 {
+		return fmt.Errorf("RTSP DESCRIBE 状态码: %d %s", resp.statusCode, resp.status)
+	}
+
+	if base := resp.header.Get("Content-Base"); base != "" {
+		r.baseURL = base
+	} else if loc := resp.header.Get("Content-Location"); loc != "" {
+		r.baseURL = loc
+	}
+
+	tracks, sessionControl := parseSDP(resp.body)
+	for _, t := range tracks {
+		if !t.supported() {
+			r.tracks = append(r.tracks, t)
+			continue
+		}
+		t.channel = len(r.byChannel) * 2
+		transport := fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", t.channel, t.channel+1)
+		headers := map[string]string{"Transport": transport}
+		if r.session != "" {
+			headers["Session"] = r.session
+		}
+		resp, err := r.request("SETUP", r.controlURL(t.control), headers)
+		if err != nil {
+			return fmt.Errorf("RTSP SETUP 失败: %w", err)
+		}
+		if resp.statusCode != 200 {
+			return fmt.Errorf("RTSP SETUP 状态码: %d %s", resp.statusCode, resp.status)
+		}
+		if r.session == "" {
+			session, _, _ := strings.Cut(resp.header.Get("Session"), ";")
+			r.session = strings.TrimSpace(session)
+		}
+		// 服务端可能改写通道号
+		if ch, ok := parseInterleaved(resp.header.Get("Transport")); ok {
+			t.channel = ch
+		}
+		t.initCodec()
+		r.byChannel[t.channel] = t
+		r.tracks = append(r.tracks, t)
+	}
+	if len(r.byChannel) == 0 {
+		return fmt.Errorf("SDP 中没有支持的媒体轨道（支持 H264 / H265 / AAC）")
+	}
+
+	playURL := r.baseURL
+	if sessionControl != "" && sessionControl != "*" {
+		playURL = r.controlURL(sessionControl)
+	}
+	resp, err = r.request("PLAY", playURL, map[string]string{
+		"Session": r.session,
+		"Range":   "npt=0.000-",
+	})
+	if err != nil {
+		return fmt.Errorf("RTSP PLAY 失败: %w", err)
+	}
+	if resp.statusCode != 200 {
+		return fmt.Errorf("RTSP PLAY 状态码: %d %s", resp.statusCode, resp.status)
+	}
+
+	// RTP-Info 中各轨道的 rtptime 对应同一个播放起点，以它作为时间戳起点使音视频对齐
+	r.applyRTPInfo(resp.header.Get("RTP-Info"))
+
+	// 在解析数据前先输出 SDP 中带的参数集
+	for _, t := range r.tracks {
+		r.emitSDPConfig(t)
+	}
+	return nil
+}
+
+// applyRTPInfo 解析 RTP-Info 头（url=...;seq=...;rtptime=...，多个轨道以逗号分隔），设置各轨道的时间戳起点
+func (r *rtspReader) applyRTPInfo(rtpInfo string) {
+	for _, entry := range strings.Split(rtpInfo, ",") {
+		var trackURL, rtptime string
+		for _, param := range strings.Split(entry, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			switch strings.ToLower(key) {
+			case "url":
+				trackURL = value
+			case "rtptime":
+				rtptime = value
+			}
+		}
+		ts, err := strconv.ParseUint(rtptime, 10, 32)
+		if trackURL == "" || err != nil {
+			continue
+		}
+		for _, t := range r.tracks {
+			if t.aligned || !r.matchControl(t, trackURL) {
+				continue
+			}
+			t.aligned = true
+			t.hasTS = true
+			t.firstTS = uint32(ts)
+			t.lastTS = uint32(ts)
+		}
+	}
+}
+
+// videoPTS RTP 时间戳是采样时刻（PTS），解码顺序下带 B 帧时会回退
+func (r *rtspReader) videoPTS() bool { return true }
+
+// matchControl RTP-Info 中的 url 是否指向该轨道（绝对地址或 SDP 中的 control 属性）
+func (r *rtspReader) matchControl(t *rtspTrack, trackURL string) bool {
+	if trackURL == r.controlURL(t.control) || trackURL == t.control {
+		return true
+	}
+	return t.control != "" && strings.HasSuffix(trackURL, "/"+t.control)
+}
+
+// avAligned 音视频轨道的时间戳是否在同一时间轴上
+// RTP 时间戳的起始值是随机的，只有 RTP-Info 给出了所有已 SETUP 轨道的 rtptime 时才能比较音视频时间戳
+// （不支持的轨道没有 SETUP，服务端不会为它返回 rtptime）
+func (r *rtspReader) avAligned() bool {
+	for _, t := range r.byChannel {
+		if !t.aligned {
+			return false
+		}
+	}
+	return true
+}
+
+// parseInterleaved 从 Transport 头中取出 interleaved RTP 通道号
+func parseInterleaved(transport string) (int, bool) {
+	for _, part := range strings.Split(transport, ";") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(part), "interleaved="); ok {
+			first, _, _ := strings.Cut(v, "-")
+			ch, err := strconv.Atoi(first)
+			return ch, err == nil
+		}
+	}
+	return 0, false
+}
+
+// controlURL 根据 a=control 计算 SETUP / PLAY 的地址
+func (r *rtspReader) controlURL(control string) string {
+	if control == "" || control == "*" {
+		return r.baseURL
+	}
+	if strings.HasPrefix(strings.ToLower(control), "rtsp://") {
+		return control
+	}
+	if strings.HasSuffix(r.baseURL, "/") {
+		return r.baseURL + control
+	}
+	return r.baseURL + "/" + control
+}
+
+// setAuth 根据 WWW-Authenticate 设置认证方式（Basic / Digest）
+func (r *rtspReader) setAuth(user *urlpkg.Userinfo, challenge string) error {
+	username := user.Username()
+	password, _ := user.Password()
+
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		token := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		r.authorization = func(string, string) string {
+			return "Basic " + token
+		}
+	case "digest":
+		attrs := parseAuthParams(params)
+		realm, nonce, qop := attrs["realm"], attrs["nonce"], attrs["qop"]
+		md5hex := func(s string) string {
+			sum := md5.Sum([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+		ha1 := md5hex(username + ":" + realm + ":" + password)
+		nc := 0
+		r.authorization = func(method, uri string) string {
+			ha2 := md5hex(method + ":" + uri)
+			if strings.Contains(qop, "auth") {
+				nc++
+				cnonce := fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+				ncStr := fmt.Sprintf("%08x", nc)
+				response := md5hex(ha1 + ":" + nonce + ":" + ncStr + ":" + cnonce + ":auth:" + ha2)
+				return fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", qop=auth, nc=%s, cnonce="%s", response="%s"`,
+					username, realm, nonce, uri, ncStr, cnonce, response)
+			}
+			response := md5hex(ha1 + ":" + nonce + ":" + ha2)
+			return fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+				username, realm, nonce, uri, response)
+		}
+	default:
+		return fmt.Errorf("不支持的 RTSP 认证方式: %s", scheme)
+	}
+	return nil
+}
+
+// parseAuthParams 解析 WWW-Authenticate / Authorization 头的参数列表（key=token 或 key="quoted"，逗号分隔）
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			token, next, _ := strings.Cut(rest, ",")
+			value.WriteString(strings.TrimSpace(token))
+			s = next
+		}
+		params[key] = value.String()
+	}
+}
+
+// userAgent 请求使用的 User-Agent（request 配置优先）
+func (r *rtspReader) userAgent() string {
+	if ua := r.header.Get("User-Agent"); ua != "" {
+		return ua
+	}
+	return rtspUserAgent
+}
+
+// request 发送 RTSP 请求并读取响应
+func (r *rtspReader) request(method, uri string, headers map[string]string) (*rtspResponse, error) {
+	r.cseq++
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s RTSP/1.0\r\n", method, uri)
+	fmt.Fprintf(&b, "CSeq: %d\r\n", r.cseq)
+	fmt.Fprintf(&b, "User-Agent: %s\r\n", r.userAgent())
+	for name, values := range r.header {
+		if name == "User-Agent" {
+			continue
+		}
+		for _, v := range values {
+			fmt.Fprintf(&b, "%s: %s\r\n", name, v)
+		}
+	}
+	if r.authorization != nil {
+		fmt.Fprintf(&b, "Authorization: %s\r\n", r.authorization(method, uri))
+	}
+	for k, v := range headers {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+	b.WriteString("\r\n")
+
+	if _, err := io.WriteString(r.nc, b.String()); err != nil {
+		return nil, err
+	}
+
+	for {
+		// 响应之前可能夹杂 interleaved 数据，跳过
+		first, err := r.br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if first[0] == '$' {
+			if _, _, err := r.readInterleaved(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		resp, err := r.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			continue
+		}
+		if cseq := resp.header.Get("CSeq"); cseq != "" && cseq != strconv.Itoa(r.cseq) {
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// readMessage 读取一个 RTSP 消息（起始行 + 头 + 可选 body）
+// 服务端主动发来的请求（例如 GET_PARAMETER / OPTIONS 心跳、ANNOUNCE）直接回复，返回 nil
+func (r *rtspReader) readMessage() (*rtspResponse, error) {
+	tp := textproto.NewReader(r.br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	first, rest, _ := strings.Cut(line, " ")
+	isResponse := strings.HasPrefix(first, "RTSP/")
+	if !isResponse {
+		// 请求行：METHOD URI RTSP/1.0
+		if _, proto, _ := strings.Cut(rest, " "); !strings.HasPrefix(proto, "RTSP/") {
+			return nil, fmt.Errorf("无效的 RTSP 消息: %q", line)
+		}
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var body []byte
+	if n, _ := strconv.Atoi(header.Get("Content-Length")); n > 0 {
+		body = make([]byte, n)
+		if _, err := io.ReadFull(r.br, body); err != nil {
+			return nil, err
+		}
+	}
+
+	if !isResponse {
+		return nil, r.answerRequest(first, header)
+	}
+	codeStr, status, _ := strings.Cut(rest, " ")
+	code, err := strconv.Atoi(codeStr)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 RTSP 状态码: %q", line)
+	}
+	return &rtspResponse{statusCode: code, status: status, header: header, body: body}, nil
+}
+
+// answerRequest 回复服务端发来的请求：心跳类请求回复 200，其余回复 501
+func (r *rtspReader) answerRequest(method string, header textproto.MIMEHeader) error {
+	status := "200 OK"
+	switch method {
+	case "OPTIONS", "GET_PARAMETER", "SET_PARAMETER":
+	default:
+		status = "501 Not Implemented"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "RTSP/1.0 %s\r\n", status)
+	fmt.Fprintf(&b, "CSeq: %s\r\n", header.Get("CSeq"))
+	if r.session != "" {
+		fmt.Fprintf(&b, "Session: %s\r\n", r.session)
+	}
+	b.WriteString("\r\n")
+	_, err := io.WriteString(r.nc, b.String())
+	return err
+}
+
+// readInterleaved 读取一个 '$' + channel + length 封装的数据块
+func (r *rtspReader) readInterleaved() (int, []byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r.br, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	size := int(binary.BigEndian.Uint16(hdr[2:4]))
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+	data := r.buf[:size]
+	if _, err := io.ReadFull(r.br, data); err != nil {
+		return 0, nil, err
+	}
+	return int(hdr[1]), data, nil
+}
+
+// ReadPacket 读取下一个音视频包
+func (r *rtspReader) ReadPacket() (av.Packet, error) {
+	for {
+		if len(r.pending) > 0 {
+			pkt := r.pending[0]
+			r.pending = r.pending[1:]
+			return pkt, nil
+		}
+
+		first, err := r.br.Peek(1)
+		if err != nil {
+			return av.Packet{}, err
+		}
+		if first[0] != '$' {
+			// 服务端发来的 RTSP 消息：响应（例如 keepalive 的响应）丢弃，请求由 readMessage 回复
+			if _, err := r.readMessage(); err != nil {
+				return av.Packet{}, err
+			}
+			continue
+		}
+
+		channel, data, err := r.readInterleaved()
+		if err != nil {
+			return av.Packet{}, err
+		}
+		t, ok := r.byChannel[channel]
+		if !ok {
+			// RTCP 或未知通道
+			continue
+		}
+		r.handleRTP(t, data)
+	}
+}
+
+// handleRTP 解析 RTP 包头，统计序号并按编码解包
+func (r *rtspReader) handleRTP(t *rtspTrack, b []byte) {
+	if len(b) < 12 || b[0]>>6 != 2 {
+		return
+	}
+	padding := b[0]&0x20 != 0
+	extension := b[0]&0x10 != 0
+	csrcCount := int(b[0] & 0x0f)
+	marker := b[1]&0x80 != 0
+	seq := binary.BigEndian.Uint16(b[2:4])
+	ts := binary.BigEndian.Uint32(b[4:8])
+	ssrc := binary.BigEndian.Uint32(b[8:12])
+
+	offset := 12 + 4*csrcCount
+	if extension {
+		if len(b) < offset+4 {
+			return
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(b[offset+2:offset+4]))
+	}
+	end := len(b)
+	if padding && end > 0 {
+		end -= int(b[end-1])
+	}
+	if offset > end {
+		return
+	}
+	payload := b[offset:end]
+
+	if r.firstReadTime.IsZero() {
+		*r.firstReadTime = time.Now()
+	}
+
+	// RTP 序号统计：前向缺口计为丢包，后向跳变计为乱序
+	t.received++
+	if t.hasSeq && ssrc != t.ssrc {
+		// SSRC 变化（摄像头重启或切换信源）：序号重新开始
+		t.resyncSeq()
+	}
+	if t.hasSeq {
+		if diff := seq - (t.lastSeq + 1); diff != 0 && diff < 0x8000 {
+			t.lost += int64(diff)
+			t.gaps++
+			// 丢包时丢弃正在组装的分片
+			t.fuActive = false
+			t.fuBuf = t.fuBuf[:0]
+		} else if diff != 0 {
+			t.staleRun++
+			if t.staleRun < rtpResyncPackets {
+				t.reordered++
+				return
+			}
+			// 连续多个包都"落后"：发送端序号大幅跳变，这些包不是乱序，从当前包重新同步
+			t.reordered -= int64(t.staleRun - 1)
+			t.resyncSeq()
+		}
+	}
+	t.hasSeq = true
+	t.lastSeq = seq
+	t.ssrc = ssrc
+	t.staleRun = 0
+
+	switch t.codec {
+	case "H264":
+		r.depacketizeH264(t, payload, ts, marker)
+	case "H265":
+		r.depacketizeH265(t, payload, ts, marker)
+	case "MPEG4-GENERIC":
+		r.depacketizeAAC(t, payload, ts)
+	}
+}
+
+// resyncSeq 序号不连续时重新同步，按一次序号缺口记录（无法得知丢了多少包，不计入丢包数）
+func (t *rtspTrack) resyncSeq() {
+	t.gaps++
+	t.hasSeq = false
+	t.staleRun = 0
+	t.fuActive = false
+	t.fuBuf = t.fuBuf[:0]
+}
+
+// rtpTime 展开 32 位 RTP 时间戳并换算为相对起点的时间（起点见 applyRTPInfo）
+func (t *rtspTrack) rtpTime(ts uint32) time.Duration {
+	if !t.hasTS {
+		t.hasTS = true
+		t.firstTS = ts
+		t.lastTS = ts
+	}
+	if diff := int32(ts - t.lastTS); diff > 0 && ts < t.lastTS {
+		t.tsBase += 1 << 32
+	} else if diff < 0 && ts > t.lastTS {
+		t.tsBase -= 1 << 32
+	}
+	t.lastTS = ts
+	ext := t.tsBase + int64(ts) - int64(t.firstTS)
+	if t.clockRate <= 0 {
+		return 0
+	}
+	return time.Duration(ext) * time.Second / time.Duration(t.clockRate)
+}
+
+// initCodec 根据 SDP 初始化解包参数
+func (t *rtspTrack) initCodec() {
+	if t.clockRate == 0 {
+		t.clockRate = 90000
+	}
+	if t.codec == "MPEG4-GENERIC" {
+		t.sizeLength, _ = strconv.Atoi(t.fmtp["sizelength"])
+		t.indexLength, _ = strconv.Atoi(t.fmtp["indexlength"])
+		t.indexDeltaLength, _ = strconv.Atoi(t.fmtp["indexdeltalength"])
+		if t.sizeLength == 0 {
+			t.sizeLength, t.indexLength, t.indexDeltaLength = 13, 3, 3
+		}
+		if b, err := hex.DecodeString(t.fmtp["config"]); err == nil && len(b) > 0 {
+			if config, err := aac.ParseMPEG4AudioConfigBytes(b); err == nil {
+				t.aacConfig = &config
+			}
+		}
+	}
+}
+
+// emitSDPConfig 输出 SDP 中携带的解码配置（sprop-parameter-sets / sprop-vps 等 / config）
+func (r *rtspReader) emitSDPConfig(t *rtspTrack) {
+	switch t.codec {
+	case "H264":
+		for _, ps := range strings.Split(t.fmtp["sprop-parameter-sets"], ",") {
+			if nalu, err := base64.StdEncoding.DecodeString(ps); err == nil && len(nalu) > 0 {
+				r.addH264ParamSet(t, nalu)
+			}
+		}
+	case "H265":
+		// RFC 7798：sprop-vps / sprop-sps / sprop-pps 各自为逗号分隔的 base64 NALU
+		var paramSets [3][][]byte
+		for i, key := range []string{"sprop-vps", "sprop-sps", "sprop-pps"} {
+			for _, ps := range strings.Split(t.fmtp[key], ",") {
+				if nalu, err := base64.StdEncoding.DecodeString(ps); err == nil && len(nalu) > 0 {
+					paramSets[i] = append(paramSets[i], nalu)
+				}
+			}
+		}
+		if config, err := hevcDecoderConfig(paramSets[0], paramSets[1], paramSets[2]); err == nil {
+			r.pending = append(r.pending, av.Packet{Type: pktH265DecoderConfig, Data: config})
+		}
+	case "MPEG4-GENERIC":
+		if t.aacConfig != nil {
+			if pkt, err := aacDecoderConfigPacket(*t.aacConfig); err == nil {
+				r.pending = append(r.pending, pkt)
+			}
+		}
+	}
+}
+
+// addH264ParamSet 记录 SPS/PPS，首次凑齐时输出 H264DecoderConfig
+func (r *rtspReader) addH264ParamSet(t *rtspTrack, nalu []byte) {
+	if t.h264Codec == nil {
+		t.h264Codec = h264.NewCodec()
+	}
+	t.h264Codec.AddSPSPPS(nalu)
+	if !t.h264Config && len(t.h264Codec.SPS) > 0 && len(t.h264Codec.PPS) > 0 {
+		t.h264Config = true
+		r.pending = append(r.pending, av.Packet{
+			Type: av.H264DecoderConfig,
+			Data: avcDecoderConfig(t.h264Codec),
+			H264: t.h264Codec,
+		})
+	}
+}
+
+// appendNALU 向当前帧追加一个 NALU，时间戳变化时先输出上一帧
+func (r *rtspReader) appendNALU(t *rtspTrack, nalu []byte, ts uint32) {
+	if len(t.frameNALUs) > 0 && ts != t.frameTS {
+		r.flushFrame(t)
+	}
+	if t.frameSize+len(nalu) > rtspMaxFrameSize {
+		return
+	}
+	t.frameTS = ts
+	t.frameNALUs = append(t.frameNALUs, append([]byte(nil), nalu...))
+	t.frameSize += len(nalu)
+}
+
+// flushFrame 将组装好的访问单元输出为视频包
+func (r *rtspReader) flushFrame(t *rtspTrack) {
+	if len(t.frameNALUs) == 0 {
+		return
+	}
+	nalus := t.frameNALUs
+	t.frameNALUs = nil
+	t.frameSize = 0
+
+	pkt := av.Packet{Time: t.rtpTime(t.frameTS)}
+	switch t.codec {
+	case "H264":
+		pkt.Type = av.H264
+		for _, nalu := range nalus {
+			switch h264.NALUType(nalu) {
+			case h264.NALU_SPS, h264.NALU_PPS:
+				r.addH264ParamSet(t, nalu)
+			case h264.NALU_IDR:
+				pkt.IsKeyFrame = true
+			}
+		}
+	case "H265":
+		pkt.Type = pktH265
+		for _, nalu := range nalus {
+			if h265IsIRAP(nalu) {
+				pkt.IsKeyFrame = true
+			}
+		}
+	}
+	pkt.Data = h264.JoinNALUsAVCC(nalus)
+	r.pending = append(r.pending, pkt)
+}
+
+// depacketizeH264 RFC 6184：单 NALU / STAP-A / FU-A
+func (r *rtspReader) depacketizeH264(t *rtspTrack, payload []byte, ts uint32, marker bool) {
+	if len(payload) < 1 {
+		return
+	}
+	switch typ := payload[0] & 0x1f; {
+	case typ >= 1 && typ <= 23:
+		r.appendNALU(t, payload, ts)
+
+	case typ == 24: // STAP-A
+		b := payload[1:]
+		for len(b) >= 2 {
+			size := int(binary.BigEndian.Uint16(b))
+			if size == 0 || 2+size > len(b) {
+				break
+			}
+			r.appendNALU(t, b[2:2+size], ts)
+			b = b[2+size:]
+		}
+
+	case typ == 28: // FU-A
+		if len(payload) < 2 {
+			return
+		}
+		fuHeader := payload[1]
+		start := fuHeader&0x80 != 0
+		end := fuHeader&0x40 != 0
+		if start {
+			t.fuBuf = append(t.fuBuf[:0], payload[0]&0xe0|fuHeader&0x1f)
+			t.fuActive = true
+		}
+		if !t.fuActive {
+			return
+		}
+		t.fuBuf = append(t.fuBuf, payload[2:]...)
+		if end {
+			r.appendNALU(t, t.fuBuf, ts)
+			t.fuActive = false
+			t.fuBuf = t.fuBuf[:0]
+		}
+	}
+
+	if marker {
+		r.flushFrame(t)
+	}
+}
+
+// depacketizeH265 RFC 7798：单 NALU / AP / FU
+func (r *rtspReader) depacketizeH265(t *rtspTrack, payload []byte, ts uint32, marker bool) {
+	if len(payload) < 2 {
+		return
+	}
+	switch typ := (payload[0] >> 1) & 0x3f; {
+	case typ < 48:
+		r.appendNALU(t, payload, ts)
+
+	case typ == 48: // AP
+		b := payload[2:]
+		for len(b) >= 2 {
+			size := int(binary.BigEndian.Uint16(b))
+			if size == 0 || 2+size > len(b) {
+				break
+			}
+			r.appendNALU(t, b[2:2+size], ts)
+			b = b[2+size:]
+		}
+
+	case typ == 49: // FU
+		if len(payload) < 3 {
+			return
+		}
+		fuHeader := payload[2]
+		start := fuHeader&0x80 != 0
+		end := fuHeader&0x40 != 0
+		if start {
+			nalType := fuHeader & 0x3f
+			t.fuBuf = append(t.fuBuf[:0], payload[0]&0x81|nalType<<1, payload[1])
+			t.fuActive = true
+		}
+		if !t.fuActive {
+			return
+		}
+		t.fuBuf = append(t.fuBuf, payload[3:]...)
+		if end {
+			r.appendNALU(t, t.fuBuf, ts)
+			t.fuActive = false
+			t.fuBuf = t.fuBuf[:0]
+		}
+	}
+
+	if marker {
+		r.flushFrame(t)
+	}
+}
+
+// depacketizeAAC RFC 3640（mpeg4-generic, AAC-hbr）：AU-headers + AU 数据
+func (r *rtspReader) depacketizeAAC(t *rtspTrack, payload []byte, ts uint32) {
+	if len(payload) < 2 {
+		return
+	}
+	headersBits := int(binary.BigEndian.Uint16(payload))
+	headersBytes := (headersBits + 7) / 8
+	if 2+headersBytes > len(payload) {
+		return
+	}
+	headers := payload[2 : 2+headersBytes]
+	data := payload[2+headersBytes:]
+
+	headerBits := t.sizeLength + t.indexLength
+	if headerBits <= 0 {
+		return
+	}
+	base := t.rtpTime(ts)
+	samplesPerFrame := time.Duration(1024)
+	bitPos := 0
+	for i := 0; bitPos+headerBits <= headersBits; i++ {
+		size := readBits(headers, bitPos, t.sizeLength)
+		bitPos += headerBits
+		if size <= 0 || size > len(data) {
+			return
+		}
+		pkt := av.Packet{Type: av.AAC, Time: base, Data: append([]byte(nil), data[:size]...)}
+		if t.clockRate > 0 {
+			pkt.Time = base + time.Duration(i)*samplesPerFrame*time.Second/time.Duration(t.clockRate)
+		}
+		r.pending = append(r.pending, pkt)
+		data = data[size:]
+	}
+}
+
+// readBits 从字节数组的指定比特位置读取 n 位（大端）
+func readBits(b []byte, pos, n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		byteIdx := (pos + i) / 8
+		if byteIdx >= len(b) {
+			return v
+		}
+		bit := (b[byteIdx] >> (7 - uint((pos+i)%8))) & 1
+		v = v<<1 | int(bit)
+	}
+	return v
+}
+
+// Close 发送 TEARDOWN 并关闭连接
+func (r *rtspReader) Close() error {
+	if r.nc == nil {
+		return nil
+	}
+	if r.session != "" {
+		r.nc.SetWriteDeadline(time.Now().Add(time.Second))
+		fmt.Fprintf(r.nc, "TEARDOWN %s RTSP/1.0\r\nCSeq: %d\r\nSession: %s\r\nUser-Agent: %s\r\n\r\n",
+			r.baseURL, r.cseq+1, r.session, r.userAgent())
+	}
+	return r.nc.Close()
+}
+
+// TransportStats 汇总所有已 SETUP 轨道的 RTP 序号统计
+func (r *rtspReader) TransportStats() TransportStats {
+	var stats RTSPStats
+	for _, t := range r.byChannel {
+		stats.Packets += t.received
+		stats.LostPackets += t.lost
+		stats.SeqGaps += t.gaps
+		stats.Reordered += t.reordered
+	}
+	if expected := stats.Packets + stats.LostPackets; expected > 0 {
+		stats.LossRatio = float64(stats.LostPackets) / float64(expected)
+	}
+	stats.Info = r.sdpInfo()
+	return TransportStats{ResponseTime: r.responseTime, RemoteIP: connRemoteIP(r.nc), RTSP: stats}
+}
+
+// sdpInfo 返回 SDP 中的编码信息（第一个视频轨道和第一个音频轨道）
+func (r *rtspReader) sdpInfo() RTSPInfo {
+	var info RTSPInfo
+	for _, t := range r.tracks {
+		switch t.media {
+		case "video":
+			if info.VideoCodec == "" {
+				info.VideoCodec = t.codec
+				info.VideoProfile = t.fmtp["profile-level-id"]
+			}
+		case "audio":
+			if info.AudioCodec == "" {
+				info.AudioCodec = t.codec
+				if t.clockRate > 0 {
+					info.AudioSampleRate = strconv.Itoa(t.clockRate)
+				}
+				if t.channels > 0 {
+					info.AudioChannels = strconv.Itoa(t.channels)
+				}
+			}
+		}
+	}
+	return info
+}
+
+// RTSPInfo RTSP SDP 中声明的编码信息
+type RTSPInfo struct {
+	VideoCodec      string // 例如 H264 / H265
+	VideoProfile    string // H.264 profile-level-id（十六进制）
+	AudioCodec      string // 例如 MPEG4-GENERIC / PCMA / PCMU
+	AudioSampleRate string
+	AudioChannels   string
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nareix/joy5/av"
+	"github.com/nareix/joy5/codec/h264"
+)
+
+// 测试用参数集：H.264 Baseline 1280x720，H.265 Main 1920x1080
+const (
+	testH264SPS = "Z0IAH5WoFAFuQA=="
+	testH264PPS = "aM48gA=="
+	testH265VPS = "QAEMAf//AWAAAAMAkAAAAwAAAwB4mZgJ"
+	testH265SPS = "QgEBAWAAAAMAkAAAAwAAAwB4oAPAgBDlmZpJMrwFoCAAAAMAIAAAAwPh"
+	testH265PPS = "RAHgdrAmQA=="
+)
+
+// newTestTrackingReader 测试用的读取统计模板
+func newTestTrackingReader() *stallTrackingReader {
+	return &stallTrackingReader{
+		totalBytes:     new(int64),
+		stallCount:     new(int64),
+		maxStall:       new(time.Duration),
+		totalStall:     new(time.Duration),
+		firstReadTime:  new(time.Time),
+		stallThreshold: time.Second,
+	}
+}
+
+// testRTSPConn 测试用 RTSP 服务端连接
+type testRTSPConn struct {
+	tp *textproto.Reader
+	nc net.Conn
+}
+
+// readRequest 读取一个请求，返回方法、地址和请求头
+func (c *testRTSPConn) readRequest() (string, string, textproto.MIMEHeader, error) {
+	line, err := c.tp.ReadLine()
+	if err != nil {
+		return "", "", nil, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return "", "", nil, fmt.Errorf("无效的请求行: %q", line)
+	}
+	header, err := c.tp.ReadMIMEHeader()
+	if err != nil {
+		return "", "", nil, err
+	}
+	return fields[0], fields[1], header, nil
+}
+
+// expect 读取一个请求并检查方法
+func (c *testRTSPConn) expect(method string) (string, textproto.MIMEHeader, error) {
+	m, uri, header, err := c.readRequest()
+	if err != nil {
+		return "", nil, err
+	}
+	if m != method {
+		return "", nil, fmt.Errorf("期望 %s，收到 %s", method, m)
+	}
+	return uri, header, nil
+}
+
+// reply 回复一个响应，headers 按 "名称: 值" 逐行给出
+func (c *testRTSPConn) reply(req textproto.MIMEHeader, status string, body string, headers ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "RTSP/1.0 %s\r\nCSeq: %s\r\n", status, req.Get("CSeq"))
+	for _, h := range headers {
+		b.WriteString(h + "\r\n")
+	}
+	if body != "" {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(body))
+	}
+	b.WriteString("\r\n" + body)
+	_, err := io.WriteString(c.nc, b.String())
+	return err
+}
+
+// writeRTP 写出一个 interleaved RTP 包
+func (c *testRTSPConn) writeRTP(channel byte, pt byte, seq uint16, ts uint32, marker bool, payload []byte) error {
+	rtp := make([]byte, 12, 12+len(payload))
+	rtp[0] = 0x80
+	rtp[1] = pt
+	if marker {
+		rtp[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(rtp[2:], seq)
+	binary.BigEndian.PutUint32(rtp[4:], ts)
+	binary.BigEndian.PutUint32(rtp[8:], 0x12345678)
+	rtp = append(rtp, payload...)
+
+	frame := []byte{'$', channel, 0, 0}
+	binary.BigEndian.PutUint16(frame[2:], uint16(len(rtp)))
+	_, err := c.nc.Write(append(frame, rtp...))
+	return err
+}
+
+// checkDigest 按 RFC 2617（qop=auth）校验 Authorization 头
+func checkDigest(authorization, method, username, password string) error {
+	scheme, params, _ := strings.Cut(authorization, " ")
+	if scheme != "Digest" {
+		return fmt.Errorf("期望 Digest 认证，收到 %q", authorization)
+	}
+	attrs := parseAuthParams(params)
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := md5hex(username + ":" + attrs["realm"] + ":" + password)
+	ha2 := md5hex(method + ":" + attrs["uri"])
+	want := md5hex(ha1 + ":" + attrs["nonce"] + ":" + attrs["nc"] + ":" + attrs["cnonce"] + ":auth:" + ha2)
+	if attrs["username"] != username || attrs["response"] != want {
+		return fmt.Errorf("Digest 响应错误: %q", authorization)
+	}
+	return nil
+}
+
+// TestRTSPSession 完整的 RTSP 会话：Digest 认证、SETUP / PLAY、服务端心跳请求，
+// 以及 H.264（单 NALU / FU-A / STAP-A）和 AAC（RFC 3640）解包、序号缺口统计
+func TestRTSPSession(t *testing.T) {
+	const (
+		username = "admin"
+		password = "secret"
+		nonce    = "dcd98b7102dd2f0e8b11d0f600bfb0c0"
+	)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	base := "rtsp://" + ln.Addr().String() + "/live/test/"
+
+	sdp := strings.Join([]string{
+		"v=0",
+		"o=- 0 0 IN IP4 127.0.0.1",
+		"s=test",
+		"t=0 0",
+		"a=control:*",
+		"m=video 0 RTP/AVP 96",
+		"a=rtpmap:96 H264/90000",
+		"a=fmtp:96 packetization-mode=1;profile-level-id=42001F;sprop-parameter-sets=" + testH264SPS + "," + testH264PPS,
+		"a=control:trackID=0",
+		"m=audio 0 RTP/AVP 97",
+		"a=rtpmap:97 MPEG4-GENERIC/48000/2",
+		"a=fmtp:97 streamtype=5;profile-level-id=1;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=1190",
+		"a=control:trackID=1",
+		"",
+	}, "\r\n")
+
+	idr := []byte{0x65, 0x88, 0x84, 0x00, 0x33, 0xff}
+	slice := append([]byte{0x41}, bytes.Repeat([]byte{0x9a}, 20)...)
+	sei := []byte{0x06, 0x05, 0x01, 0x80}
+	slice2 := []byte{0x41, 0x9b, 0x02, 0x03}
+	au1 := bytes.Repeat([]byte{0x21}, 5)
+	au2 := bytes.Repeat([]byte{0x22}, 7)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer nc.Close()
+		serverErr <- func() error {
+			c := &testRTSPConn{tp: textproto.NewReader(bufio.NewReader(nc)), nc: nc}
+
+			_, req, err := c.expect("OPTIONS")
+			if err != nil {
+				return err
+			}
+			if err := c.reply(req, "200 OK", "", "Public: OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN"); err != nil {
+				return err
+			}
+
+			// 第一次 DESCRIBE 不带认证，返回 401
+			_, req, err = c.expect("DESCRIBE")
+			if err != nil {
+				return err
+			}
+			if req.Get("Authorization") != "" {
+				return fmt.Errorf("第一次 DESCRIBE 不应携带认证")
+			}
+			challenge := fmt.Sprintf(`WWW-Authenticate: Digest realm="test", nonce="%s", qop="auth"`, nonce)
+			if err := c.reply(req, "401 Unauthorized", "", challenge); err != nil {
+				return err
+			}
+			_, req, err = c.expect("DESCRIBE")
+			if err != nil {
+				return err
+			}
+			if err := checkDigest(req.Get("Authorization"), "DESCRIBE", username, password); err != nil {
+				return err
+			}
+			if err := c.reply(req, "200 OK", sdp, "Content-Type: application/sdp", "Content-Base: "+base); err != nil {
+				return err
+			}
+
+			for i, track := range []string{"trackID=0", "trackID=1"} {
+				uri, req, err := c.expect("SETUP")
+				if err != nil {
+					return err
+				}
+				if uri != base+track {
+					return fmt.Errorf("SETUP 地址 %q，期望 %q", uri, base+track)
+				}
+				if err := checkDigest(req.Get("Authorization"), "SETUP", username, password); err != nil {
+					return err
+				}
+				transport := fmt.Sprintf("Transport: RTP/AVP/TCP;unicast;interleaved=%d-%d", i*2, i*2+1)
+				if err := c.reply(req, "200 OK", "", transport, "Session: 12345678;timeout=60"); err != nil {
+					return err
+				}
+			}
+
+			_, req, err = c.expect("PLAY")
+			if err != nil {
+				return err
+			}
+			if req.Get("Session") != "12345678" {
+				return fmt.Errorf("PLAY Session %q", req.Get("Session"))
+			}
+			rtpInfo := fmt.Sprintf("RTP-Info: url=%strackID=0;seq=1;rtptime=1000,url=%strackID=1;seq=1;rtptime=5000", base, base)
+			if err := c.reply(req, "200 OK", "", "Session: 12345678", rtpInfo); err != nil {
+				return err
+			}
+
+			// PLAY 之后服务端发来的心跳请求
+			if _, err := io.WriteString(nc, "GET_PARAMETER "+base+" RTSP/1.0\r\nCSeq: 9\r\nSession: 12345678\r\n\r\n"); err != nil {
+				return err
+			}
+
+			// 视频：单 NALU 的 IDR
+			if err := c.writeRTP(0, 96, 1, 1000, true, idr); err != nil {
+				return err
+			}
+			// 视频：FU-A 分成 3 个包
+			fu := slice[1:]
+			for i, part := range [][]byte{fu[:7], fu[7:14], fu[14:]} {
+				fuHeader := slice[0] & 0x1f
+				if i == 0 {
+					fuHeader |= 0x80
+				}
+				if i == 2 {
+					fuHeader |= 0x40
+				}
+				payload := append([]byte{slice[0]&0xe0 | 28, fuHeader}, part...)
+				if err := c.writeRTP(0, 96, uint16(2+i), 4000, i == 2, payload); err != nil {
+					return err
+				}
+			}
+			// 音频：一个 RTP 包两个 AU，AU-header 为 13 位长度 + 3 位索引
+			aac := []byte{0, 32}
+			aac = binary.BigEndian.AppendUint16(aac, uint16(len(au1))<<3)
+			aac = binary.BigEndian.AppendUint16(aac, uint16(len(au2))<<3)
+			aac = append(append(aac, au1...), au2...)
+			if err := c.writeRTP(2, 97, 1, 5000, true, aac); err != nil {
+				return err
+			}
+			// 视频：丢掉序号 5，STAP-A 携带 SEI 和片
+			stap := []byte{24}
+			for _, nalu := range [][]byte{sei, slice2} {
+				stap = binary.BigEndian.AppendUint16(stap, uint16(len(nalu)))
+				stap = append(stap, nalu...)
+			}
+			if err := c.writeRTP(0, 96, 6, 7000, true, stap); err != nil {
+				return err
+			}
+
+			// 客户端对心跳请求的回复
+			line, err := c.tp.ReadLine()
+			if err != nil {
+				return err
+			}
+			if line != "RTSP/1.0 200 OK" {
+				return fmt.Errorf("GET_PARAMETER 回复 %q", line)
+			}
+			header, err := c.tp.ReadMIMEHeader()
+			if err != nil {
+				return err
+			}
+			if header.Get("CSeq") != "9" {
+				return fmt.Errorf("GET_PARAMETER 回复 CSeq %q", header.Get("CSeq"))
+			}
+			// 等待客户端关闭
+			io.Copy(io.Discard, nc)
+			return nil
+		}()
+	}()
+
+	rawURL := fmt.Sprintf("rtsp://%s:%s@%s/live/test", username, password, ln.Addr())
+	prober := newRTSPProber(rawURL, newTestTrackingReader())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := prober.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var pkts []av.Packet
+	for len(pkts) < 7 {
+		pkt, err := prober.ReadPacket()
+		if err != nil {
+			t.Fatalf("读取第 %d 个包: %v", len(pkts)+1, err)
+		}
+		pkts = append(pkts, pkt)
+	}
+	stats := prober.TransportStats().RTSP
+	aligned := prober.(avSyncProber).avAligned()
+	prober.Close()
+	if err := <-serverErr; err != nil {
+		t.Fatalf("服务端: %v", err)
+	}
+
+	// SDP 中的解码配置
+	if pkts[0].Type != av.H264DecoderConfig {
+		t.Fatalf("第 1 个包类型 %v，期望 H264DecoderConfig", pkts[0].Type)
+	}
+	if codec, err := h264.FromDecoderConfig(pkts[0].Data); err != nil || len(codec.SPS) != 1 || len(codec.PPS) != 1 {
+		t.Errorf("H264 解码配置 %x: %v", pkts[0].Data, err)
+	}
+	if pkts[1].Type != av.AACDecoderConfig || !bytes.Equal(pkts[1].Data, []byte{0x11, 0x90}) {
+		t.Errorf("AAC 解码配置 %v %x", pkts[1].Type, pkts[1].Data)
+	}
+
+	ms := func(n int64, rate int64) time.Duration { return time.Duration(n) * time.Second / time.Duration(rate) }
+	want := []struct {
+		typ      int
+		time     time.Duration
+		keyframe bool
+		nalus    [][]byte
+		data     []byte
+	}{
+		{typ: av.H264, time: 0, keyframe: true, nalus: [][]byte{idr}},
+		{typ: av.H264, time: ms(3000, 90000), nalus: [][]byte{slice}},
+		{typ: av.AAC, time: 0, data: au1},
+		{typ: av.AAC, time: ms(1024, 48000), data: au2},
+		{typ: av.H264, time: ms(6000, 90000), nalus: [][]byte{sei, slice2}},
+	}
+	for i, w := range want {
+		pkt := pkts[i+2]
+		if pkt.Type != w.typ || pkt.Time != w.time || pkt.IsKeyFrame != w.keyframe {
+			t.Errorf("第 %d 个包: 类型 %v 时间 %v 关键帧 %v，期望 %v %v %v", i+3, pkt.Type, pkt.Time, pkt.IsKeyFrame, w.typ, w.time, w.keyframe)
+			continue
+		}
+		if w.typ == av.AAC {
+			if !bytes.Equal(pkt.Data, w.data) {
+				t.Errorf("第 %d 个包数据 %x，期望 %x", i+3, pkt.Data, w.data)
+			}
+			continue
+		}
+		nalus, _ := h264.SplitNALUs(pkt.Data)
+		if len(nalus) != len(w.nalus) {
+			t.Errorf("第 %d 个包 NALU 数 %d，期望 %d", i+3, len(nalus), len(w.nalus))
+			continue
+		}
+		for j := range nalus {
+			if !bytes.Equal(nalus[j], w.nalus[j]) {
+				t.Errorf("第 %d 个包 NALU %d: %x，期望 %x", i+3, j, nalus[j], w.nalus[j])
+			}
+		}
+	}
+
+	if stats.Packets != 6 || stats.LostPackets != 1 || stats.SeqGaps != 1 || stats.Reordered != 0 {
+		t.Errorf("RTP 统计 %+v，期望 6 个包、丢 1 个、1 次缺口", stats)
+	}
+	wantInfo := RTSPInfo{VideoCodec: "H264", VideoProfile: "42001F", AudioCodec: "MPEG4-GENERIC", AudioSampleRate: "48000", AudioChannels: "2"}
+	if stats.Info != wantInfo {
+		t.Errorf("SDP 编码信息 %+v，期望 %+v", stats.Info, wantInfo)
+	}
+	if !aligned {
+		t.Error("RTP-Info 给出了所有轨道的 rtptime，音视频应已对齐")
+	}
+}
+
+func TestParseSDP(t *testing.T) {
+	sdp := strings.Join([]string{
+		"v=0",
+		"s=test",
+		"a=control:rtsp://example.com/live",
+		"m=video 0 RTP/AVP 98",
+		"a=rtpmap:98 h265/90000",
+		"a=fmtp:98 sprop-vps=" + testH265VPS + "; sprop-sps=" + testH265SPS + "; sprop-pps=" + testH265PPS,
+		"a=control:streamid=0",
+		"m=audio 0 RTP/AVP 8",
+		"a=control:streamid=1",
+		"m=application 0 RTP/AVP 107",
+		"a=rtpmap:107 vnd.onvif.metadata/90000",
+	}, "\n")
+
+	tracks, sessionControl := parseSDP([]byte(sdp))
+	if sessionControl != "rtsp://example.com/live" {
+		t.Errorf("会话 control %q", sessionControl)
+	}
+	if len(tracks) != 3 {
+		t.Fatalf("轨道数 %d，期望 3", len(tracks))
+	}
+
+	video, audio, meta := tracks[0], tracks[1], tracks[2]
+	if video.media != "video" || video.codec != "H265" || video.payloadType != 98 || video.clockRate != 90000 || video.control != "streamid=0" {
+		t.Errorf("视频轨道 %+v", video)
+	}
+	if video.fmtp["sprop-sps"] != testH265SPS {
+		t.Errorf("sprop-sps %q", video.fmtp["sprop-sps"])
+	}
+	// 静态载荷类型 8 没有 rtpmap
+	if audio.media != "audio" || audio.codec != "PCMA" || audio.clockRate != 8000 || audio.supported() {
+		t.Errorf("音频轨道 %+v", audio)
+	}
+	if meta.media != "application" || meta.supported() {
+		t.Errorf("元数据轨道 %+v", meta)
+	}
+
+	// H.265 的 sprop-vps / sprop-sps / sprop-pps 生成 hvcC
+	r := &rtspReader{}
+	r.emitSDPConfig(video)
+	if len(r.pending) != 1 || r.pending[0].Type != pktH265DecoderConfig {
+		t.Fatalf("H265 解码配置 %+v", r.pending)
+	}
+	info, err := parseHEVCDecoderConfig(r.pending[0].Data)
+	if err != nil || info.Width != 1920 || info.Height != 1080 || info.Profile != "Main" {
+		t.Errorf("H265 解码配置 %+v: %v", info, err)
+	}
+}
+
+func TestRTSPApplyRTPInfo(t *testing.T) {
+	// 元数据轨道不支持，没有 SETUP，RTP-Info 中也不会有它的 rtptime
+	newReader := func() *rtspReader {
+		video := &rtspTrack{media: "video", control: "trackID=1", clockRate: 90000, channel: 0}
+		audio := &rtspTrack{media: "audio", control: "trackID=11", clockRate: 48000, channel: 2}
+		meta := &rtspTrack{media: "application", control: "trackID=12", clockRate: 90000}
+		return &rtspReader{
+			baseURL:   "rtsp://example.com/live/",
+			tracks:    []*rtspTrack{video, audio, meta},
+			byChannel: map[int]*rtspTrack{0: video, 2: audio},
+		}
+	}
+
+	r := newReader()
+	r.applyRTPInfo("url=rtsp://example.com/live/trackID=1;seq=7;rtptime=90000, url=trackID=11;seq=3;rtptime=4294967000")
+	if !r.avAligned() {
+		t.Fatal("两个轨道都有 rtptime，应已对齐")
+	}
+	if got := r.tracks[0].rtpTime(90000 + 9000); got != 100*time.Millisecond {
+		t.Errorf("视频时间 %v，期望 100ms", got)
+	}
+	// rtptime 之后回绕
+	if got := r.tracks[1].rtpTime(4504); got != 100*time.Millisecond { // 4294967000 + 4800 - 2^32
+		t.Errorf("音频时间 %v，期望 100ms", got)
+	}
+
+	// 只有一个轨道有 rtptime（trackID=1 不能匹配 trackID=11）
+	r = newReader()
+	r.applyRTPInfo("url=rtsp://example.com/live/trackID=1;seq=7;rtptime=90000")
+	if r.avAligned() || r.tracks[1].aligned {
+		t.Error("音频轨道没有 rtptime，不应对齐")
+	}
+}
+
+func TestRTSPSeqResync(t *testing.T) {
+	r := newRTSPProber("rtsp://example.com/live", newTestTrackingReader()).(*rtspReader)
+	track := &rtspTrack{media: "audio", codec: "PCMA", clockRate: 8000}
+	send := func(seq uint16, ssrc uint32) {
+		b := make([]byte, 13)
+		b[0] = 0x80
+		binary.BigEndian.PutUint16(b[2:], seq)
+		binary.BigEndian.PutUint32(b[8:], ssrc)
+		r.handleRTP(track, b)
+	}
+
+	for seq := uint16(100); seq <= 102; seq++ {
+		send(seq, 1)
+	}
+	send(100, 1) // 重复包
+	send(103, 1)
+	// 发送端序号前跳超过一半：前几个包按乱序丢弃，连续 rtpResyncPackets 个后重新同步
+	for seq := uint16(0x9000); seq < 0x9000+rtpResyncPackets; seq++ {
+		send(seq, 1)
+	}
+	send(0x9000+rtpResyncPackets, 1)
+	// SSRC 变化：序号重新开始
+	send(5, 2)
+	send(6, 2)
+
+	if track.received != 16 || track.lost != 0 || track.gaps != 2 || track.reordered != 1 || track.lastSeq != 6 {
+		t.Errorf("RTP 统计 received=%d lost=%d gaps=%d reordered=%d lastSeq=%d，期望 16 0 2 1 6",
+			track.received, track.lost, track.gaps, track.reordered, track.lastSeq)
+	}
+}
+
+func TestParseAuthParams(t *testing.T) {
+	got := parseAuthParams(`realm="IP Camera(C1234)", Nonce="a1b2,c3" , qop="auth,auth-int", stale=FALSE, opaque="say \"hi\""`)
+	want := map[string]string{
+		"realm":  "IP Camera(C1234)",
+		"nonce":  "a1b2,c3",
+		"qop":    "auth,auth-int",
+		"stale":  "FALSE",
+		"opaque": `say "hi"`,
+	}
+	if len(got) != len(want) {
+		t.Errorf("参数 %q，期望 %q", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s=%q，期望 %q", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/nareix/joy5/av"
+)
+
+// getSampleParams 获取采样参数（从配置读取，默认采样 10 秒、至少 2 个关键帧）
+func getSampleParams() (sampleDuration time.Duration, minKeyframes int) {
+	sampleDurationSec := 10
+	minKeyframes = 2
+	if globalConfig != nil {
+		if globalConfig.Exporter.SampleDuration > 0 {
+			sampleDurationSec = globalConfig.Exporter.SampleDuration
+		}
+		if globalConfig.Exporter.MinKeyframes > 0 {
+			minKeyframes = globalConfig.Exporter.MinKeyframes
+		}
+	}
+	return time.Duration(sampleDurationSec) * time.Second, minKeyframes
+}
+
+// newStreamSampler 创建采样器
+// log 用于输出时间戳跳变等调试日志；wireBytes 为网络读取的累计字节数，用于识别初始突发
+func newStreamSampler(allowAudioOnly bool, log *slog.Logger, wireBytes *int64) *streamSampler {
+	jumpThreshold := getDTSJumpThreshold()
+	return &streamSampler{
+		allowAudioOnly: allowAudioOnly,
+		audio:          audioSampler{gapThreshold: getAudioGapThreshold()},
+		videoDTS:       dtsTracker{track: "video", threshold: jumpThreshold, log: log},
+		audioDTS:       dtsTracker{track: "audio", threshold: jumpThreshold, log: log},
+		latency:        latencySampler{metadataField: getMetadataCaptureTimeField()},
+		burst:          burstTracker{wireBytes: wireBytes},
+	}
+}
+
+// streamSampler 按时间采样音视频包，统计与协议无关的包数、关键帧和时间戳
+type streamSampler struct {
+	packetCount   int
+	videoCount    int
+	audioCount    int
+	keyframeCount int
+	videoBytes    int64 // 视频帧负载字节数（不含容器开销）
+	hasMetadata   bool
+	metadata      StreamMetadata // onMetaData 中声明的编码参数
+	codec         string         // 视频编码（第一个视频包的编码）
+	videoInfo     VideoInfo      // 从 SPS 解析的视频参数（以最后一次出现的 SPS 为准）
+	audioInfo     AudioInfo      // 从 AudioSpecificConfig 解析的音频参数
+	audio         audioSampler
+	videoDTS      dtsTracker // 视频 DTS 跳变 / 回退
+	audioDTS      dtsTracker // 音频 DTS 跳变 / 回退
+	avSync        avSyncSampler
+	intervals     FrameIntervals // 相邻视频包的 DTS 间隔和到达间隔
+	gop           gopTracker     // 相邻关键帧之间的帧数和时长
+	latency       latencySampler // 时钟漂移和端到端延迟
+	burst         burstTracker   // 连接建立后的初始突发（GOP 缓存）
+	frozen        frozenTracker  // 非关键帧大小和连续空帧（画面静止判定）
+	paramSets     [][]byte       // 解码配置中的参数集（SPS / PPS 等），用于单独解码关键帧
+	keyframe      *Keyframe      // 最后一个关键帧（H.264 / H.265，带参数集）
+
+	// allowAudioOnly 允许纯音频流：没有视频时达到采样时长即结束，不等待关键帧
+	allowAudioOnly bool
+	// segmented 包按分片成批到达（HLS），到达时间不反映发送节奏：不统计初始突发、到达间隔、时钟漂移和音频抖动
+	// 通过 setSegmented 设置，同时传给 audio
+	segmented bool
+	// skipAVSync 音视频时间戳不在同一时间轴上（RTSP 没有 RTP-Info），不计算音画同步
+	skipAVSync bool
+
+	firstPacketTime   time.Time // 第一个视频包到达的系统时间（用于是否读到包的判定）
+	firstKeyframeTime time.Time // 第一个关键帧到达的系统时间
+	firstGOPTime      time.Time // 第一个完整 GOP 收齐的系统时间（第二个关键帧到达时）
+	lastVideoTime     time.Time // 最后一个视频包到达的系统时间
+	firstDTS          int64     // 第一个视频包的DTS
+	lastDTS           int64     // 最后一个视频包的DTS
+}
+
+// run 从 reader 读取数据包直到采样结束
+// 提前退出条件：达到采样时长且收集到足够关键帧；超过采样时长的 2 倍时无论如何都退出
+func (s *streamSampler) run(reader av.PacketReader, sampleDuration time.Duration, minKeyframes int) error {
+	sampleStartTime := time.Now()
+
+	for {
+		// 基于时间的采样，提前退出条件：达到采样时间且收集到足够关键帧
+		elapsed := time.Since(sampleStartTime)
+		if elapsed >= sampleDuration && s.keyframeCount >= minKeyframes {
+			return nil
+		}
+		// 纯音频流没有关键帧，达到采样时间即退出
+		if elapsed >= sampleDuration && s.allowAudioOnly && s.videoCount == 0 && s.audioCount > 0 {
+			return nil
+		}
+
+		// 如果已经超过采样时间，即使关键帧不够也退出（避免长时间阻塞）
+		if elapsed >= sampleDuration*2 {
+			return nil
+		}
+
+		pkt, err := reader.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("读取数据包失败: %w", err)
+		}
+		pktRecvTime := time.Now() // 记录包到达时间
+
+		s.observe(pkt, pktRecvTime)
+	}
+}
+
+// setSegmented 设置包是否按分片成批到达（见 segmented）
+func (s *streamSampler) setSegmented(segmented bool) {
+	s.segmented = segmented
+	s.audio.segmented = segmented
+}
+
+// observe 统计一个数据包
+func (s *streamSampler) observe(pkt av.Packet, recvTime time.Time) {
+	s.packetCount++
+
+	// 检查 metadata（只在第一次收到时记录）
+	if pkt.Type == av.Metadata && !s.hasMetadata {
+		s.hasMetadata = true
+	}
+	// onMetaData：记录声明的编码参数（以最后一次出现的为准），并读取自定义的采集时间字段
+	if pkt.Type == av.Metadata {
+		if metadata, ok := parseOnMetaData(pkt.Data); ok {
+			s.metadata = newStreamMetadata(metadata)
+			s.latency.observeMetadata(metadata, recvTime)
+		}
+	}
+
+	// joy5: 使用 Type 判断包类型
+	switch pkt.Type {
+	case av.H264DecoderConfig:
+		// AVC sequence header（FLV/RTMP）或由带内 SPS/PPS 生成的解码配置（TS/RTSP）
+		if info, err := parseAVCDecoderConfig(pkt.Data); err == nil {
+			s.videoInfo = info
+		}
+		s.paramSets = decoderConfigParamSets(pkt)
+	case pktH265DecoderConfig:
+		if info, err := parseHEVCDecoderConfig(pkt.Data); err == nil {
+			s.videoInfo = info
+		}
+		s.paramSets = decoderConfigParamSets(pkt)
+	case pktAV1DecoderConfig:
+		if info, err := parseAV1DecoderConfig(pkt.Data); err == nil {
+			s.videoInfo = info
+		}
+	case pktVP9DecoderConfig:
+		if info, err := parseVP9DecoderConfig(pkt.Data); err == nil {
+			s.videoInfo = info
+		}
+	case av.H264, pktH265, pktAV1, pktVP9:
+		s.videoCount++
+		s.videoBytes += int64(len(pkt.Data))
+
+		if pkt.IsKeyFrame {
+			s.keyframeCount++
+			switch s.keyframeCount {
+			case 1:
+				s.firstKeyframeTime = recvTime
+			case 2:
+				s.firstGOPTime = recvTime
+			}
+			// 关键帧中带内的参数集（分辨率切换时编码器通常只更新带内参数集）
+			s.observeKeyFrame(pkt)
+			if keyframe, ok := newKeyframe(pkt, s.paramSets, recvTime); ok {
+				s.keyframe = keyframe
+			}
+		}
+
+		// 记录时间戳和到达时间
+		if s.firstPacketTime.IsZero() {
+			s.firstPacketTime = recvTime
+			s.firstDTS = int64(pkt.Time)
+		} else {
+			s.observeFrameInterval(pkt.Time, recvTime)
+		}
+		s.lastDTS = int64(pkt.Time)
+		s.lastVideoTime = recvTime
+		s.gop.observe(pkt.Time, pkt.IsKeyFrame)
+		s.latency.observeVideo(pkt, recvTime)
+		if !s.segmented {
+			s.burst.observe(pkt.Time, recvTime)
+		}
+		s.frozen.observe(pkt.Time, len(pkt.Data), pkt.IsKeyFrame)
+		s.videoDTS.observe(pkt.Time)
+		if !s.skipAVSync {
+			s.avSync.observeVideo(pkt.Time)
+		}
+
+		if s.codec == "" {
+			s.codec = videoCodecName(pkt.Type)
+		}
+	case av.AACDecoderConfig:
+		if info, err := parseAACDecoderConfig(pkt.Data); err == nil {
+			s.audioInfo = info
+		}
+	case av.AAC:
+		s.audioCount++
+		s.audio.observe(pkt.Time, len(pkt.Data), recvTime)
+		s.audioDTS.observe(pkt.Time)
+		s.avSync.observeAudio(pkt.Time)
+	}
+}
+
+// observeKeyFrame 从关键帧的带内参数集（SPS / AV1 序列头 / VP9 帧头）更新视频参数
+func (s *streamSampler) observeKeyFrame(pkt av.Packet) {
+	var info VideoInfo
+	var ok bool
+	switch pkt.Type {
+	case av.H264:
+		info, ok = findH264SPS(pkt.Data)
+	case pktH265:
+		info, ok = findH265SPS(pkt.Data)
+	case pktAV1:
+		info, ok = findAV1SequenceHeader(pkt.Data)
+	case pktVP9:
+		info, ok = parseVP9KeyFrame(pkt.Data)
+		// VP9 帧头中没有 level，沿用 vpcC 中的值
+		if ok && s.videoInfo.Codec == "VP9" {
+			info.LevelIdc, info.Level = s.videoInfo.LevelIdc, s.videoInfo.Level
+		}
+	}
+	if ok {
+		s.videoInfo = info
+	}
+}
+
+// observeFrameInterval 记录与上一个视频包的 DTS 间隔和到达间隔（分片到达时只记录 DTS 间隔，只有 PTS 时只记录到达间隔）
+// 回退和超过跳变阈值的 DTS 间隔已计入时间戳连续性统计，不进入间隔分布
+func (s *streamSampler) observeFrameInterval(dts time.Duration, recvTime time.Time) {
+	if !s.segmented {
+		s.intervals.ArrivalMs = append(s.intervals.ArrivalMs, recvTime.Sub(s.lastVideoTime).Seconds()*1000)
+	}
+	if s.videoDTS.pts {
+		return
+	}
+	if delta := dts - time.Duration(s.lastDTS); delta >= 0 && delta <= s.videoDTS.threshold {
+		s.intervals.DTSMs = append(s.intervals.DTSMs, delta.Seconds()*1000)
+	}
+}
+
+// StartupStats 起播耗时（从发起请求开始计时，未达到的阶段为 0）
+type StartupStats struct {
+	FirstVideoPacketMs float64 // 到第一个视频包
+	FirstKeyframeMs    float64 // 到第一个关键帧（播放器最早可以解码出画面的时间）
+	FirstGOPMs         float64 // 到第一个完整 GOP（第二个关键帧到达）
+}
+
+// startupStats 计算相对 reqStart 的起播耗时
+func (s *streamSampler) startupStats(reqStart time.Time) StartupStats {
+	sinceStart := func(t time.Time) float64 {
+		if t.IsZero() {
+			return 0
+		}
+		return t.Sub(reqStart).Seconds() * 1000
+	}
+	return StartupStats{
+		FirstVideoPacketMs: sinceStart(s.firstPacketTime),
+		FirstKeyframeMs:    sinceStart(s.firstKeyframeTime),
+		FirstGOPMs:         sinceStart(s.firstGOPTime),
+	}
+}
+
+// timestampStats 时间戳跳变和回退统计
+func (s *streamSampler) timestampStats() TimestampStats {
+	return TimestampStats{
+		VideoDiscontinuities: s.videoDTS.discontinuities,
+		VideoBackward:        s.videoDTS.backward,
+		AudioDiscontinuities: s.audioDTS.discontinuities,
+		AudioBackward:        s.audioDTS.backward,
+	}
+}
+
+// gopSize 计算 GOP 大小（关键帧间隔的帧数）
+// 有完整 GOP 时取完整 GOP 的平均帧数，否则按总帧数估算
+func (s *streamSampler) gopSize() int {
+	switch {
+	case len(s.gop.frameCounts) > 0:
+		return int(math.Round(s.gopStats().FramesMean))
+	case s.keyframeCount > 1:
+		// 简单方法：总帧数 / 关键帧数
+		return s.videoCount / s.keyframeCount
+	case s.keyframeCount == 1:
+		// 只有一个关键帧，GOP就是所有帧
+		return s.videoCount
+	default:
+		// 没有关键帧，设为0
+		return 0
+	}
+}
+
+// gopStats 完整 GOP 的帧数和时长统计
+func (s *streamSampler) gopStats() GOPStats {
+	return s.gop.stats(getMaxGOPDuration())
+}
+
+// dtsElapsed 第一个到最后一个视频包的 DTS 跨度（秒），没有 DTS 时返回 0
+func (s *streamSampler) dtsElapsed() float64 {
+	if s.firstPacketTime.IsZero() || s.lastDTS <= s.firstDTS {
+		return 0
+	}
+	return float64(s.lastDTS-s.firstDTS) / 1e9 // 纳秒转秒
+}
+
+// videoBitrate 视频码率（bps）：视频帧负载字节数 / 视频 DTS 跨度，没有 DTS 时返回 0
+func (s *streamSampler) videoBitrate() float64 {
+	elapsed := s.dtsElapsed()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.videoBytes*8) / elapsed
+}
+
+// playable 是否可播放：至少 2 个关键帧且超过 10 个视频帧
+func (s *streamSampler) playable() bool {
+	return s.keyframeCount >= 2 && s.videoCount > 10
+}
+
+// audioOnlyPlayable 纯音频流是否可播放：超过 10 个音频帧
+func (s *streamSampler) audioOnlyPlayable() bool {
+	return s.audioCount > 10
+}
+
+// evaluateAudioQuality 纯音频流的质量评估：基于断流次数
+func evaluateAudioQuality(playable bool, gaps int) string {
+	if !playable {
+		return "poor"
+	}
+	if gaps == 0 {
+		return "good"
+	}
+	// 有断流但仍可播放
+	return "fair"
+}
+
+// evaluateQuality 质量评估：基于帧率和码率
+func evaluateQuality(playable bool, framerate, bitrate float64) string {
+	if !playable {
+		return "poor"
+	}
+	if framerate >= 25 && bitrate >= 600000 {
+		// 高质量：帧率>=25fps，码率>=600kbps
+		return "good"
+	} else if framerate >= 20 && bitrate >= 400000 {
+		// 中等质量：帧率>=20fps，码率>=400kbps
+		return "fair"
+	}
+	// 低质量
+	return "poor"
+}
+
+// linearFit 最小二乘直线拟合的累加量（音画漂移、时钟漂移共用）
+type linearFit struct {
+	n     int
+	sumX  float64
+	sumY  float64
+	sumXX float64
+	sumXY float64
+}
+
+// add 加入一个样本
+func (f *linearFit) add(x, y float64) {
+	f.n++
+	f.sumX += x
+	f.sumY += y
+	f.sumXX += x * x
+	f.sumXY += x * y
+}
+
+// meanY 样本 y 的平均值，没有样本时返回 0
+func (f *linearFit) meanY() float64 {
+	if f.n == 0 {
+		return 0
+	}
+	return f.sumY / float64(f.n)
+}
+
+// slope 拟合直线的斜率，样本不足（x 全部相同）时返回 0
+func (f *linearFit) slope() float64 {
+	n := float64(f.n)
+	denom := n*f.sumXX - f.sumX*f.sumX
+	if denom <= 0 {
+		return 0
+	}
+	return (n*f.sumXY - f.sumX*f.sumY) / denom
+}
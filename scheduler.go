@@ -13,6 +13,7 @@ type Scheduler struct {
 	config   *Config
 	mu       sync.RWMutex
 	stopChan chan struct{}
+	onCheck  func(StreamMetrics) // 每次检查成功后调用（导出器据此记录直方图和计数器）
 	log      *slog.Logger
 }
 
@@ -27,15 +28,20 @@ func NewScheduler(config *Config) *Scheduler {
 }
 
 // AddStream 添加流
-func (s *Scheduler) AddStream(id, url, project, line string, labels map[string]string) {
+func (s *Scheduler) AddStream(cfg StreamConfig, project, line string, labels map[string]string) error {
+	checker, err := NewStreamChecker(cfg, project, line, labels)
+	if err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	key := fmt.Sprintf("%s::%s::%s", project, line, url)
-	checker := NewStreamChecker(id, url, project, line, labels)
+	key := fmt.Sprintf("%s::%s::%s", project, line, cfg.URL)
 	s.checkers[key] = checker
 
-	s.log.Info("添加流", "流ID", id, "URL", url, "项目", project, "线路", line)
+	s.log.Info("添加流", "流ID", cfg.ID, "URL", cfg.URL, "协议", checker.protocol, "项目", project, "线路", line)
+	return nil
 }
 
 // Start 启动调度器
@@ -91,12 +97,15 @@ func (s *Scheduler) runCheckCycle() {
 		go func(c *StreamChecker) {
 			defer wg.Done()
 
-			// 获取信号量
+			// 获取信号量，执行检查，带重试
 			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			// 执行检查，带重试
 			err := s.checkWithRetry(c)
+			<-semaphore
+
+			// 开启 probe_all_edges 时再分别检查每个边缘节点（每个节点单独占用并发名额）
+			if c.probeAllEdges {
+				s.checkEdges(c, semaphore)
+			}
 
 			// 统计成功/失败数量
 			mu.Lock()
@@ -119,9 +128,8 @@ func (s *Scheduler) runCheckCycle() {
 		"总数", len(checkers))
 }
 
-// checkWithRetry 带重试的检查
-// 返回 nil 表示成功，返回 error 表示所有重试都失败
-func (s *Scheduler) checkWithRetry(checker *StreamChecker) error {
+// checkTimeout 单次检查的超时时间
+func (s *Scheduler) checkTimeout() time.Duration {
 	// 超时时间：采样时间 + 网络缓冲(5秒)
 	sampleDurationSec := 10
 	if s.config.Exporter.SampleDuration > 0 {
@@ -133,40 +141,86 @@ func (s *Scheduler) checkWithRetry(checker *StreamChecker) error {
 	if s.config.Exporter.CheckInterval > 20 {
 		timeout = time.Duration(s.config.Exporter.CheckInterval-5) * time.Second
 	}
+	return timeout
+}
+
+// checkWithRetry 带重试的检查
+// 返回 nil 表示成功，返回 error 表示所有重试都失败
+func (s *Scheduler) checkWithRetry(checker *StreamChecker) error {
+	timeout := s.checkTimeout()
+	log := s.log.With("流ID", checker.id)
+	if checker.edgeIP != "" {
+		log = log.With("边缘节点", checker.edgeIP)
+	}
 
 	var lastErr error
 	for attempt := 0; attempt <= s.config.Exporter.MaxRetries; attempt++ {
 		if attempt > 0 {
 			// 指数退避：2^attempt 秒（2s, 4s, 8s, ...）
 			retryDelay := time.Duration(1<<uint(attempt)) * time.Second
-			s.log.Info("等待重试", "流ID", checker.id, "尝试次数", attempt, "延迟秒", retryDelay.Seconds())
+			log.Info("等待重试", "尝试次数", attempt, "延迟秒", retryDelay.Seconds())
 			time.Sleep(retryDelay)
 		}
 
 		err := checker.Check(timeout)
 		if err == nil {
-			// 成功
+			// 成功（质量为 poor 时保存本次采样）
+			checker.saveCapture(nil)
+			// 边缘节点的标签与主检查相同，只输出逐节点 Gauge，不进入直方图 / 计数器（否则会重复计数）
+			if checker.edgeIP == "" {
+				s.notifyCheck(checker)
+			}
 			return nil
 		}
 
 		lastErr = err
-		s.log.Warn("检查失败", "流ID", checker.id, "尝试次数", attempt+1, "最大重试", s.config.Exporter.MaxRetries+1, "错误", err)
+		log.Warn("检查失败", "尝试次数", attempt+1, "最大重试", s.config.Exporter.MaxRetries+1, "错误", err)
 	}
 
-	// 所有重试都失败
+	// 所有重试都失败（保存最后一次尝试的采样）
 	checker.MarkFailed()
-	s.log.Error("达到最大重试次数，标记为失败",
-		"流ID", checker.id,
+	checker.saveCapture(lastErr)
+	log.Error("达到最大重试次数，标记为失败",
 		"总尝试次数", s.config.Exporter.MaxRetries+1,
 		"最后错误", lastErr)
 	return lastErr
 }
 
+// OnCheckComplete 设置检查成功后的回调（每次成功的检查调用一次，与抓取频率无关）
+func (s *Scheduler) OnCheckComplete(fn func(StreamMetrics)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onCheck = fn
+}
+
+// notifyCheck 把本次检查的结果交给回调
+func (s *Scheduler) notifyCheck(checker *StreamChecker) {
+	s.mu.RLock()
+	onCheck := s.onCheck
+	s.mu.RUnlock()
+	if onCheck != nil {
+		onCheck(checker.GetMetrics())
+	}
+}
+
 // Stop 停止调度器
 func (s *Scheduler) Stop() {
 	close(s.stopChan)
 }
 
+// GetChecker 按 Key（project:line:id）查找流检查器，找不到时返回 nil
+func (s *Scheduler) GetChecker(key string) *StreamChecker {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, checker := range s.checkers {
+		if checker.Key() == key {
+			return checker
+		}
+	}
+	return nil
+}
+
 // GetAllMetrics 获取所有流的指标
 func (s *Scheduler) GetAllMetrics() []StreamMetrics {
 	s.mu.RLock()
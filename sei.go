@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"time"
+
+	"github.com/nareix/joy5/codec/h264"
+)
+
+// SEI NALU 类型和 SEI 消息类型
+const (
+	h264NALUSEI       = 6
+	h265NALUPrefixSEI = 39
+	h265NALUSuffixSEI = 40
+
+	seiUserDataUnregistered = 5
+)
+
+// misbMicrosecTimeUUID MISB ST 0604 精确时间戳（user_data_unregistered）的 UUID
+var misbMicrosecTimeUUID = []byte("MISPmicrosectime")
+
+// ntpEpochOffset NTP 纪元（1900 年）与 Unix 纪元（1970 年）之间的秒数
+const ntpEpochOffset = 2208988800
+
+// 采集时间来源
+const (
+	captureTimeSEIMISB   = "sei_misb"    // MISB ST 0604 微秒时间戳
+	captureTimeSEINTP    = "sei_ntp"     // 8 字节 NTP 时间戳
+	captureTimeSEIUnixMs = "sei_unix_ms" // ASCII 十进制 Unix 毫秒时间戳
+	captureTimeMetadata  = "metadata"    // onMetaData 中的自定义字段
+)
+
+// findSEICaptureTime 在访问单元（AVCC 或 Annex B）的 SEI user_data_unregistered 中查找采集时间
+// now 用于合理性检查：与 now 相差超过 24 小时的时间戳视为无效（例如编码器写入的其他自定义数据）
+func findSEICaptureTime(data []byte, hevc bool, now time.Time) (time.Time, string, bool) {
+	nalus, _ := h264.SplitNALUs(data)
+	for _, nalu := range nalus {
+		headerLen := 1
+		if hevc {
+			typ := h265NALUType(nalu)
+			if typ != h265NALUPrefixSEI && typ != h265NALUSuffixSEI {
+				continue
+			}
+			headerLen = 2
+		} else if len(nalu) == 0 || nalu[0]&0x1f != h264NALUSEI {
+			continue
+		}
+		if len(nalu) <= headerLen {
+			continue
+		}
+
+		rbsp := h264.RemoveH264orH265EmulationBytes(nalu[headerLen:])
+		for _, payload := range seiUserDataUnregisteredPayloads(rbsp) {
+			t, source, ok := parseUserDataCaptureTime(payload)
+			if !ok {
+				continue
+			}
+			if diff := now.Sub(t); diff > 24*time.Hour || diff < -24*time.Hour {
+				continue
+			}
+			return t, source, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// seiUserDataUnregisteredPayloads 拆分 SEI RBSP 中的消息，返回 user_data_unregistered 的负载（含 16 字节 UUID）
+func seiUserDataUnregisteredPayloads(rbsp []byte) [][]byte {
+	var payloads [][]byte
+	// 至少剩下 rbsp_trailing_bits 一个字节
+	for len(rbsp) > 1 {
+		payloadType, payloadSize := 0, 0
+		for len(rbsp) > 0 && rbsp[0] == 0xff {
+			payloadType += 255
+			rbsp = rbsp[1:]
+		}
+		if len(rbsp) == 0 {
+			break
+		}
+		payloadType += int(rbsp[0])
+		rbsp = rbsp[1:]
+		for len(rbsp) > 0 && rbsp[0] == 0xff {
+			payloadSize += 255
+			rbsp = rbsp[1:]
+		}
+		if len(rbsp) == 0 {
+			break
+		}
+		payloadSize += int(rbsp[0])
+		rbsp = rbsp[1:]
+		if payloadSize > len(rbsp) {
+			break
+		}
+
+		if payloadType == seiUserDataUnregistered && payloadSize >= 16 {
+			payloads = append(payloads, rbsp[:payloadSize])
+		}
+		rbsp = rbsp[payloadSize:]
+	}
+	return payloads
+}
+
+// parseUserDataCaptureTime 解析 user_data_unregistered 负载中的时间戳，支持：
+//   - MISB ST 0604：UUID "MISPmicrosectime" + 状态字节 + 8 字节微秒时间戳（每 2 字节后插入 0xFF）
+//   - 任意 UUID + 8 字节 NTP 时间戳（32 位秒 + 32 位小数）
+//   - 任意 UUID + ASCII 十进制 Unix 毫秒时间戳（13 位，可带结尾的 0 字节）
+func parseUserDataCaptureTime(payload []byte) (time.Time, string, bool) {
+	uuid, data := payload[:16], payload[16:]
+
+	if bytes.Equal(uuid, misbMicrosecTimeUUID) {
+		if len(data) < 12 || data[3] != 0xff || data[6] != 0xff || data[9] != 0xff {
+			return time.Time{}, "", false
+		}
+		ts := []byte{data[1], data[2], data[4], data[5], data[7], data[8], data[10], data[11]}
+		return time.UnixMicro(int64(binary.BigEndian.Uint64(ts))), captureTimeSEIMISB, true
+	}
+
+	if len(data) == 8 {
+		sec := int64(binary.BigEndian.Uint32(data)) - ntpEpochOffset
+		frac := int64(binary.BigEndian.Uint32(data[4:]))
+		return time.Unix(sec, frac*1e9>>32), captureTimeSEINTP, true
+	}
+
+	if digits := bytes.TrimRight(data, "\x00"); len(digits) == 13 {
+		if ms, err := strconv.ParseInt(string(digits), 10, 64); err == nil {
+			return time.UnixMilli(ms), captureTimeSEIUnixMs, true
+		}
+	}
+	return time.Time{}, "", false
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	urlpkg "net/url"
+	pathpkg "path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// getSignTTL 签名有效期（流配置 sign.ttl，默认1800秒）
+func getSignTTL(cfg SignConfig) time.Duration {
+	if cfg.TTL > 0 {
+		return time.Duration(cfg.TTL) * time.Second
+	}
+	return 1800 * time.Second // 默认值
+}
+
+// SignParams 一次签名使用的参数
+type SignParams struct {
+	Key    string    // 签名密钥（已读取 env: / file:）
+	Expire time.Time // 签名中的时间：当前时间 + ttl
+	UID    string    // 用户 ID（aliyun_a 的 uid，默认 0）
+}
+
+// URLSigner 按 CDN 的防盗链规则改写地址（追加签名参数或改写路径）
+type URLSigner func(u *urlpkg.URL, p SignParams) error
+
+var (
+	signerMu sync.RWMutex
+	signers  = make(map[string]URLSigner)
+)
+
+// RegisterURLSigner 注册签名方式，流配置 sign.type 按名称选择
+func RegisterURLSigner(name string, signer URLSigner) {
+	signerMu.Lock()
+	defer signerMu.Unlock()
+	signers[name] = signer
+}
+
+func init() {
+	RegisterURLSigner("tencent", signTencent)
+	RegisterURLSigner("aliyun_a", signAliyunA)
+	RegisterURLSigner("aliyun_b", signAliyunB)
+	RegisterURLSigner("aliyun_c", signAliyunC)
+	RegisterURLSigner("wangsu", signWangsu)
+}
+
+// lookupURLSigner 按名称查找签名方式
+func lookupURLSigner(name string) (URLSigner, error) {
+	signerMu.RLock()
+	defer signerMu.RUnlock()
+
+	signer, ok := signers[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(signers))
+		for n := range signers {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("未知签名方式: %s（支持: %s）", name, strings.Join(names, ", "))
+	}
+	return signer, nil
+}
+
+// signURL 用当前时间生成签名地址，没有配置 sign 时原样返回
+func signURL(rawURL string, cfg SignConfig, now time.Time) (string, error) {
+	if cfg.Type == "" {
+		return rawURL, nil
+	}
+	signer, err := lookupURLSigner(cfg.Type)
+	if err != nil {
+		return "", err
+	}
+	key, err := resolveSecret(cfg.Key)
+	if err != nil {
+		return "", fmt.Errorf("签名密钥: %w", err)
+	}
+	if key == "" {
+		return "", fmt.Errorf("签名密钥为空")
+	}
+	u, err := urlpkg.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("流地址无效: %w", err)
+	}
+	params := SignParams{Key: key, Expire: now.Add(getSignTTL(cfg)), UID: cfg.UID}
+	if params.UID == "" {
+		params.UID = "0"
+	}
+	if err := signer(u, params); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// md5Hex 小写十六进制的 MD5
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// queryParam 追加到地址中的签名参数
+type queryParam struct {
+	name  string
+	value string
+}
+
+// setQuery 追加签名参数：原有参数保持顺序和编码不变（源站可能校验顺序或已有的签名），只去掉同名的旧参数
+func setQuery(u *urlpkg.URL, params ...queryParam) {
+	var parts []string
+	for _, part := range strings.Split(u.RawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(part, "=")
+		if unescaped, err := urlpkg.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if slices.ContainsFunc(params, func(p queryParam) bool { return p.name == name }) {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	for _, p := range params {
+		parts = append(parts, urlpkg.QueryEscape(p.name)+"="+urlpkg.QueryEscape(p.value))
+	}
+	u.RawQuery = strings.Join(parts, "&")
+}
+
+// signTencent 腾讯云直播：txSecret = md5(key + StreamName + txTime)，txTime 为过期时间的十六进制（大写）
+// StreamName 为路径最后一段去掉扩展名（/live/8888_test.flv -> 8888_test）
+func signTencent(u *urlpkg.URL, p SignParams) error {
+	base := pathpkg.Base(u.Path)
+	streamName := strings.TrimSuffix(base, pathpkg.Ext(base))
+	if streamName == "" || streamName == "/" || streamName == "." {
+		return fmt.Errorf("无法从地址中取得 StreamName: %s", u.Path)
+	}
+	txTime := strings.ToUpper(strconv.FormatInt(p.Expire.Unix(), 16))
+	setQuery(u,
+		queryParam{"txSecret", md5Hex(p.Key + streamName + txTime)},
+		queryParam{"txTime", txTime})
+	return nil
+}
+
+// signAliyunA 阿里云 A 型鉴权：auth_key = timestamp-rand-uid-md5(URI-timestamp-rand-uid-key)，rand 固定为 0
+func signAliyunA(u *urlpkg.URL, p SignParams) error {
+	prefix := fmt.Sprintf("%d-0-%s", p.Expire.Unix(), p.UID)
+	setQuery(u, queryParam{"auth_key", prefix + "-" + md5Hex(u.Path+"-"+prefix+"-"+p.Key)})
+	return nil
+}
+
+// aliyunBZone 阿里云 B 型鉴权的时间按 UTC+8 格式化
+var aliyunBZone = time.FixedZone("UTC+8", 8*3600)
+
+// signAliyunB 阿里云 B 型鉴权：/{YYYYMMDDHHMM}/{md5(key + timestamp + URI)}{URI}
+func signAliyunB(u *urlpkg.URL, p SignParams) error {
+	timestamp := p.Expire.In(aliyunBZone).Format("200601021504")
+	u.Path = "/" + timestamp + "/" + md5Hex(p.Key+timestamp+u.Path) + u.Path
+	u.RawPath = ""
+	return nil
+}
+
+// signAliyunC 阿里云 C 型鉴权：/{md5(key + URI + timestamp)}/{timestamp}{URI}，timestamp 为十六进制（大写）
+func signAliyunC(u *urlpkg.URL, p SignParams) error {
+	timestamp := strings.ToUpper(strconv.FormatInt(p.Expire.Unix(), 16))
+	u.Path = "/" + md5Hex(p.Key+u.Path+timestamp) + "/" + timestamp + u.Path
+	u.RawPath = ""
+	return nil
+}
+
+// signWangsu 网宿时间戳防盗链：wsSecret = md5(key + URI + wsTime)，wsTime 为十进制秒
+func signWangsu(u *urlpkg.URL, p SignParams) error {
+	wsTime := strconv.FormatInt(p.Expire.Unix(), 10)
+	setQuery(u,
+		queryParam{"wsSecret", md5Hex(p.Key + u.Path + wsTime)},
+		queryParam{"wsTime", wsTime})
+	return nil
+}
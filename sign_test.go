@@ -0,0 +1,117 @@
+package main
+
+import (
+	urlpkg "net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 阿里云 A / B / C 型的向量取自阿里云 CDN 鉴权文档中的示例；
+// 腾讯云和网宿的向量按文档中的公式用独立实现（Python hashlib）计算
+func TestURLSigners(t *testing.T) {
+	tests := []struct {
+		name   string
+		signer string
+		url    string
+		params SignParams
+		want   string
+	}{
+		{
+			name:   "aliyun A",
+			signer: "aliyun_a",
+			url:    "http://cdn.example.com/video/standard/test.mp4",
+			params: SignParams{Key: "aliyuncdnexp1234", Expire: time.Unix(1444435200, 0), UID: "0"},
+			want:   "http://cdn.example.com/video/standard/test.mp4?auth_key=1444435200-0-0-23bf85053008f5c0e791667a313e28ce",
+		},
+		{
+			name:   "aliyun A keeps query order and encoding",
+			signer: "aliyun_a",
+			url:    "http://cdn.example.com/video/standard/test.mp4?z=1&auth_key=old&a=%2Fb+c&sig=AbC%3D",
+			params: SignParams{Key: "aliyuncdnexp1234", Expire: time.Unix(1444435200, 0), UID: "0"},
+			want:   "http://cdn.example.com/video/standard/test.mp4?z=1&a=%2Fb+c&sig=AbC%3D&auth_key=1444435200-0-0-23bf85053008f5c0e791667a313e28ce",
+		},
+		{
+			name:   "aliyun B",
+			signer: "aliyun_b",
+			url:    "http://cdn.example.com/4/44/44c0909bcfc20a01afaf256ca99a8b8b.mp3",
+			params: SignParams{Key: "aliyuncdnexp1234", Expire: time.Unix(1439596800, 0)}, // 2015-08-15 08:00 UTC+8
+			want:   "http://cdn.example.com/201508150800/9044548ef1527deadafa49a890a377f0/4/44/44c0909bcfc20a01afaf256ca99a8b8b.mp3",
+		},
+		{
+			name:   "aliyun C",
+			signer: "aliyun_c",
+			url:    "http://cdn.example.com/test.flv",
+			params: SignParams{Key: "aliyuncdnexp1234", Expire: time.Unix(0x55CE8100, 0)},
+			want:   "http://cdn.example.com/a37fa50a5fb8f71214b1e7c95ec7a1bd/55CE8100/test.flv",
+		},
+		{
+			name:   "tencent",
+			signer: "tencent",
+			url:    "http://play.example.com/live/8888_test.flv",
+			params: SignParams{Key: "e12c46f2612d5106e2034781ab261ca3", Expire: time.Unix(0x5867D600, 0)},
+			want:   "http://play.example.com/live/8888_test.flv?txSecret=eec06f4b673db4ee583466054d37d538&txTime=5867D600",
+		},
+		{
+			name:   "tencent keeps existing query",
+			signer: "tencent",
+			url:    "rtmp://play.example.com/live/8888_test?txSecret=old&txTime=0&a=1",
+			params: SignParams{Key: "e12c46f2612d5106e2034781ab261ca3", Expire: time.Unix(0x5867D600, 0)},
+			want:   "rtmp://play.example.com/live/8888_test?a=1&txSecret=eec06f4b673db4ee583466054d37d538&txTime=5867D600",
+		},
+		{
+			name:   "wangsu",
+			signer: "wangsu",
+			url:    "http://ws.example.com/live/test.flv",
+			params: SignParams{Key: "wangsukey123", Expire: time.Unix(1483200000, 0)},
+			want:   "http://ws.example.com/live/test.flv?wsSecret=6eababd0c6d064bad77f8c8f87160a2b&wsTime=1483200000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := lookupURLSigner(tt.signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := urlpkg.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := signer(u, tt.params); err != nil {
+				t.Fatal(err)
+			}
+			if got := u.String(); got != tt.want {
+				t.Errorf("签名地址 = %s，期望 %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignURL(t *testing.T) {
+	now := time.Unix(1439596800, 0)
+
+	// 不配置 sign 时原样返回
+	got, err := signURL("http://cdn.example.com/test.flv", SignConfig{}, now)
+	if err != nil || got != "http://cdn.example.com/test.flv" {
+		t.Fatalf("signURL() = %q, %v", got, err)
+	}
+
+	// 签名时间为当前时间 + ttl（默认1800秒），密钥从环境变量读取
+	t.Setenv("VIDEO_EXPORTER_TEST_SIGN_KEY", "aliyuncdnexp1234")
+	got, err = signURL("http://cdn.example.com/test.flv", SignConfig{Type: "ALIYUN_C", Key: "env:VIDEO_EXPORTER_TEST_SIGN_KEY"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://cdn.example.com/68ed5fc1f47a59402fbac0499ba8bd6e/55CE8808/test.flv"; got != want {
+		t.Errorf("signURL() = %s，期望 %s", got, want)
+	}
+
+	// 未知签名方式、密钥为空
+	if _, err := signURL("http://cdn.example.com/test.flv", SignConfig{Type: "unknown", Key: "k"}, now); err == nil || !strings.Contains(err.Error(), "aliyun_a") {
+		t.Errorf("未知签名方式应返回支持的列表，实际 %v", err)
+	}
+	if _, err := signURL("http://cdn.example.com/test.flv", SignConfig{Type: "tencent"}, now); err == nil {
+		t.Error("密钥为空时应返回错误")
+	}
+}
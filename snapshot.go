@@ -0,0 +1,69 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"time"
+)
+
+// 快照格式
+const (
+	snapshotFormatJPEG = "jpeg" // ffmpeg 解码后编码为 JPEG
+	snapshotFormatRaw  = "h264" // 原始 Annex B 基本流（H.265 流为 .h265）
+)
+
+// handleSnapshot 返回流最后一次采样到的关键帧：GET /streams/{key}/snapshot?format=jpeg|h264
+// key 为 project:line:id；不指定 format 时有 ffmpeg 返回 JPEG，否则返回原始基本流
+func (e *Exporter) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	checker := e.scheduler.GetChecker(key)
+	if checker == nil {
+		http.Error(w, "流不存在: "+key, http.StatusNotFound)
+		return
+	}
+	keyframe := checker.Snapshot()
+	if keyframe == nil {
+		http.Error(w, "还没有采样到关键帧（只保留 H.264 / H.265 关键帧）", http.StatusNotFound)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = snapshotFormatRaw
+		if _, err := lookupFFmpeg(); err == nil {
+			format = snapshotFormatJPEG
+		}
+	}
+
+	w.Header().Set("X-Snapshot-Codec", keyframe.Codec)
+	w.Header().Set("X-Snapshot-Time", keyframe.RecvTime.Format(time.RFC3339Nano))
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch format {
+	case snapshotFormatJPEG, "jpg":
+		data, err := keyframe.encodeJPEG(r.Context())
+		if err != nil {
+			e.log.Warn("生成快照失败", "流", key, "错误", err)
+			status := http.StatusInternalServerError
+			if _, lookupErr := lookupFFmpeg(); lookupErr != nil {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, "生成快照失败: "+err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(data)
+	case snapshotFormatRaw, "h265":
+		ext, contentType := ".h264", "video/h264"
+		if keyframe.Codec == "H265" {
+			ext, contentType = ".h265", "video/h265"
+		}
+		w.Header().Set("Content-Type", contentType)
+		if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": checker.id + ext}); disposition != "" {
+			w.Header().Set("Content-Disposition", disposition)
+		}
+		w.Write(keyframe.Data)
+	default:
+		http.Error(w, "不支持的快照格式: "+format+"（支持 jpeg / h264）", http.StatusBadRequest)
+	}
+}
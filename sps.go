@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/nareix/joy5/codec/h264"
+	"github.com/nareix/joy5/utils/bits"
+)
+
+// VideoInfo 从序列参数集（SPS / AV1 序列头 / VP9 帧头）解析出的视频参数
+type VideoInfo struct {
+	Codec          string // H264 / H265 / AV1 / VP9
+	ProfileIdc     int    // profile_idc，例如 H.264 的 66 / 77 / 100，H.265 的 1 / 2，AV1 的 seq_profile，VP9 的 profile
+	Profile        string // 例如 Baseline / Main / High
+	LevelIdc       int    // level_idc，例如 H.264 的 31 / 40，H.265 为 30×级别（例如 153），AV1 为 seq_level_idx，VP9 为 10×级别
+	Level          string // 例如 3.1 / 4.0 / 5.1
+	Width          int    // 宽（已去除裁剪区域）
+	Height         int    // 高（已去除裁剪区域）
+	ChromaFormat   int    // chroma_format_idc：0=4:0:0, 1=4:2:0, 2=4:2:2, 3=4:4:4
+	BitDepthLuma   int    // 亮度位深
+	BitDepthChroma int    // 色度位深
+}
+
+// Resolution 返回 "宽x高" 形式的分辨率，未知时返回空字符串
+func (v VideoInfo) Resolution() string {
+	if v.Width == 0 || v.Height == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", v.Width, v.Height)
+}
+
+// ChromaFormatName 返回色度采样格式名称，未解析到 SPS 时返回空字符串
+func (v VideoInfo) ChromaFormatName() string {
+	if v.Codec == "" {
+		return ""
+	}
+	switch v.ChromaFormat {
+	case 0:
+		return "4:0:0"
+	case 1:
+		return "4:2:0"
+	case 2:
+		return "4:2:2"
+	case 3:
+		return "4:4:4"
+	}
+	return ""
+}
+
+// bitReader 位读取器，读取失败后后续读取都返回 0，由调用方最后统一检查 err，避免每个字段都判断
+type bitReader struct {
+	r   *bits.GolombBitReader
+	err error
+}
+
+func newBitReader(data []byte) *bitReader {
+	return &bitReader{r: &bits.GolombBitReader{R: bytes.NewReader(data)}}
+}
+
+// u 读取 n 位无符号数（n <= 64）
+func (b *bitReader) u(n int) uint {
+	if b.err != nil {
+		return 0
+	}
+	var v uint
+	v, b.err = b.r.ReadBits(n)
+	return v
+}
+
+// ue 读取指数哥伦布编码 ue(v)
+func (b *bitReader) ue() uint {
+	if b.err != nil {
+		return 0
+	}
+	var v uint
+	v, b.err = b.r.ReadExponentialGolombCode()
+	return v
+}
+
+// skip 跳过 n 位
+func (b *bitReader) skip(n int) {
+	for n > 0 && b.err == nil {
+		step := min(n, 32)
+		b.u(step)
+		n -= step
+	}
+}
+
+// h264ProfileName H.264 profile_idc 对应的名称
+func h264ProfileName(profileIdc int, constraintFlags uint) string {
+	switch profileIdc {
+	case 66:
+		// constraint_set1_flag 表示 Constrained Baseline
+		if constraintFlags&0x40 != 0 {
+			return "Constrained Baseline"
+		}
+		return "Baseline"
+	case 77:
+		return "Main"
+	case 88:
+		return "Extended"
+	case 100:
+		return "High"
+	case 110:
+		return "High 10"
+	case 122:
+		return "High 4:2:2"
+	case 244:
+		return "High 4:4:4"
+	case 44:
+		return "CAVLC 4:4:4"
+	}
+	return fmt.Sprintf("%d", profileIdc)
+}
+
+// h264LevelName H.264 level_idc 对应的名称（level_idc=11 且 constraint_set3_flag 表示 1b）
+func h264LevelName(levelIdc int, profileIdc int, constraintFlags uint) string {
+	if levelIdc == 11 && constraintFlags&0x10 != 0 && (profileIdc == 66 || profileIdc == 77 || profileIdc == 88) {
+		return "1b"
+	}
+	if levelIdc == 9 {
+		return "1b"
+	}
+	return fmt.Sprintf("%d.%d", levelIdc/10, levelIdc%10)
+}
+
+// h264HighProfile 是否为 SPS 中带 chroma_format_idc / 位深字段的 profile
+func h264HighProfile(profileIdc int) bool {
+	switch profileIdc {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		return true
+	}
+	return false
+}
+
+// parseH264SPS 解析 H.264 SPS NALU（含 NALU 头），只解析到裁剪参数为止，不依赖 VUI
+func parseH264SPS(nalu []byte) (VideoInfo, error) {
+	if len(nalu) < 4 || h264.NALUType(nalu) != h264.NALU_SPS {
+		return VideoInfo{}, fmt.Errorf("不是 SPS")
+	}
+	br := newBitReader(h264.RemoveH264orH265EmulationBytes(nalu[1:]))
+	u, ue := br.u, br.ue
+	// se(v) 与 ue(v) 的位长相同，只跳过不取值时按 ue(v) 读取即可
+	se := func() {
+		ue()
+	}
+
+	info := VideoInfo{
+		Codec:          "H264",
+		ChromaFormat:   1,
+		BitDepthLuma:   8,
+		BitDepthChroma: 8,
+	}
+
+	profileIdc := int(u(8))
+	constraintFlags := u(8)
+	levelIdc := int(u(8))
+	ue() // seq_parameter_set_id
+
+	separateColourPlane := uint(0)
+	if h264HighProfile(profileIdc) {
+		info.ChromaFormat = int(ue())
+		if info.ChromaFormat == 3 {
+			separateColourPlane = u(1)
+		}
+		info.BitDepthLuma = int(ue()) + 8
+		info.BitDepthChroma = int(ue()) + 8
+		// qpprime_y_zero_transform_bypass_flag
+		u(1)
+		// seq_scaling_matrix_present_flag
+		if u(1) != 0 {
+			lists := 8
+			if info.ChromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if u(1) == 0 { // seq_scaling_list_present_flag
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				last, next := 8, 8
+				for j := 0; j < size; j++ {
+					if next != 0 {
+						delta := int(ue())
+						// se(v) 映射：奇数为正，偶数为负
+						if delta&1 != 0 {
+							delta = (delta + 1) / 2
+						} else {
+							delta = -delta / 2
+						}
+						next = (last + delta + 256) % 256
+					}
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+
+	// log2_max_frame_num_minus4
+	ue()
+	// pic_order_cnt_type
+	switch ue() {
+	case 0:
+		ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		u(1) // delta_pic_order_always_zero_flag
+		se() // offset_for_non_ref_pic
+		se() // offset_for_top_to_bottom_field
+		n := ue()
+		for i := uint(0); i < n && br.err == nil; i++ {
+			se()
+		}
+	}
+	ue() // max_num_ref_frames
+	u(1) // gaps_in_frame_num_value_allowed_flag
+	mbWidth := int(ue()) + 1
+	mbHeight := int(ue()) + 1
+	frameMbsOnly := int(u(1))
+	if frameMbsOnly == 0 {
+		u(1) // mb_adaptive_frame_field_flag
+	}
+	u(1) // direct_8x8_inference_flag
+
+	var cropLeft, cropRight, cropTop, cropBottom int
+	// frame_cropping_flag
+	if u(1) != 0 {
+		cropLeft = int(ue())
+		cropRight = int(ue())
+		cropTop = int(ue())
+		cropBottom = int(ue())
+	}
+	if br.err != nil {
+		return VideoInfo{}, fmt.Errorf("解析 SPS 失败: %w", br.err)
+	}
+
+	// 裁剪单位取决于色度采样格式（见 H.264 7.4.2.1.1）
+	cropUnitX, cropUnitY := 1, 2-frameMbsOnly
+	if info.ChromaFormat != 0 && separateColourPlane == 0 {
+		subWidthC, subHeightC := 2, 2
+		switch info.ChromaFormat {
+		case 2:
+			subHeightC = 1
+		case 3:
+			subWidthC, subHeightC = 1, 1
+		}
+		cropUnitX = subWidthC
+		cropUnitY = subHeightC * (2 - frameMbsOnly)
+	}
+
+	info.ProfileIdc = profileIdc
+	info.Profile = h264ProfileName(profileIdc, constraintFlags)
+	info.LevelIdc = levelIdc
+	info.Level = h264LevelName(levelIdc, profileIdc, constraintFlags)
+	info.Width = mbWidth*16 - cropUnitX*(cropLeft+cropRight)
+	info.Height = (2-frameMbsOnly)*mbHeight*16 - cropUnitY*(cropTop+cropBottom)
+	if info.Width <= 0 || info.Height <= 0 || info.Width > 16384 || info.Height > 16384 {
+		return VideoInfo{}, fmt.Errorf("SPS 分辨率无效: %dx%d", info.Width, info.Height)
+	}
+	return info, nil
+}
+
+// parseAVCDecoderConfig 从 AVCDecoderConfigurationRecord 中解析第一个 SPS
+func parseAVCDecoderConfig(data []byte) (VideoInfo, error) {
+	codec, err := h264.FromDecoderConfig(data)
+	if err != nil {
+		return VideoInfo{}, fmt.Errorf("解析 AVC 解码配置失败: %w", err)
+	}
+	spsList := h264.Map2arr(codec.SPS)
+	if len(spsList) == 0 {
+		return VideoInfo{}, fmt.Errorf("AVC 解码配置中没有 SPS")
+	}
+	return parseH264SPS(spsList[0])
+}
+
+// findH264SPS 在 AVCC 格式的访问单元中查找并解析 SPS（带内参数集）
+func findH264SPS(data []byte) (VideoInfo, bool) {
+	nalus, _ := h264.SplitNALUs(data)
+	for _, nalu := range nalus {
+		if h264.NALUType(nalu) == h264.NALU_SPS {
+			if info, err := parseH264SPS(nalu); err == nil {
+				return info, true
+			}
+		}
+	}
+	return VideoInfo{}, false
+}
@@ -13,9 +13,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/nareix/joy5/av"
-	"github.com/nareix/joy5/format/flv"
 )
 
 // 全局HTTP客户端，复用连接池
@@ -42,7 +39,8 @@ type stallTrackingReader struct {
 	totalStall     *time.Duration
 	firstReadTime  *time.Time
 	firstReadDone  bool
-	stallThreshold time.Duration // 读阻塞阈值
+	stallThreshold time.Duration  // 读阻塞阈值
+	capture        *captureBuffer // 记录读取的原始字节（开启 capture_dir 时）
 }
 
 func (r *stallTrackingReader) Read(p []byte) (n int, err error) {
@@ -60,6 +58,9 @@ func (r *stallTrackingReader) Read(p []byte) (n int, err error) {
 
 	if n > 0 {
 		*r.totalBytes += int64(n)
+		if r.capture != nil {
+			r.capture.Write(p[:n])
+		}
 	}
 
 	// 统计读阻塞
@@ -92,12 +93,21 @@ func initHTTPClient() {
 
 // StreamChecker 流检查器
 type StreamChecker struct {
-	id      string
-	url     string
-	project string
-	line    string            // 线路角色（source / service / cdn 等，小写）
-	labels  map[string]string // project/line/id + 自定义 tags
-	name    string
+	id       string
+	url      string
+	protocol string // 拉流协议（flv / hls / rtmp / rtsp），见 prober.go
+	project  string
+	line     string            // 线路角色（source / service / cdn 等，小写）
+	labels   map[string]string // project/line/id + 自定义 tags
+	name     string
+
+	allowAudioOnly bool          // 允许纯音频流（没有视频时按音频判定）
+	analyzeFrames  bool          // 解码关键帧分析画面内容（需要 ffmpeg）
+	probeAllEdges  bool          // 对域名解析出的每个边缘节点分别检查（probe_all_edges）
+	request        RequestConfig // 请求头、User-Agent、Referer、Cookie（已合并项目 / 线路默认值）
+	sign           SignConfig    // CDN 防盗链签名（url 为不带签名的地址）
+
+	edgeIP string // 子检查器连接的边缘节点 IP（probe_all_edges），主检查器为空
 
 	// 统计数据（当前检查的值，不累积）
 	mu               sync.RWMutex
@@ -107,13 +117,34 @@ type StreamChecker struct {
 	keyframes        int64 // 本次检查的关键帧数
 	currentBitrate   float64
 	avgBitrate       float64
+	videoBitrate     float64 // 视频码率（按视频帧负载计算，不含容器开销和音频）
 	bitrateHistory   []float64
 	framerate        float64
 	codec            string
 	response         int64
 	gopSize          int
+	gop              GOPStats // 完整 GOP 的帧数和时长统计
 	width            int
 	height           int
+	videoInfo        VideoInfo        // 从 SPS 解析的视频参数（profile / level / 色度 / 位深）
+	audio            AudioStats       // 音频参数、码率、抖动和断流
+	audioOnly        bool             // 本次检查是否为纯音频流
+	startup          StartupStats     // 起播耗时（首个视频包 / 关键帧 / 完整 GOP）
+	timestamps       TimestampStats   // 音视频 DTS 跳变和回退次数
+	avSync           AVSyncStats      // 音画偏移和漂移速率
+	intervals        FrameIntervals   // 相邻视频包的 DTS 间隔和到达间隔样本
+	latency          LatencyStats     // 时钟漂移和端到端延迟
+	burst            BurstStats       // 初始突发（GOP 缓存）和稳态吞吐 / 码率
+	metadata         StreamMetadata   // onMetaData 中声明的编码参数
+	metadataMismatch MetadataMismatch // 声明值与实测值的偏差
+	frozen           FrozenStats      // 基于帧大小的画面静止判定
+	frozenHistory    []float64        // 最近正常检查的非关键帧大小中位数（画面静止判定的基线）
+	content          ContentStats     // 解码关键帧的画面内容分析（开启 analyze_frames 时）
+	keyframe         *Keyframe        // 最后一次采样到的关键帧（快照），检查失败时保留
+	capture          *sampleCapture   // 最后一次检查读取的原始字节（开启 capture_dir 时），由调度器决定是否保存
+	remoteIP         string           // 最后一次检查实际连接的对端 IP，检查失败时也更新
+	tls              TLSInfo          // 最后一次检查的对端证书和 TLS 握手信息，检查失败时也更新（证书过期时仍有证书信息）
+	edges            []*StreamChecker // 各边缘节点的子检查器（按 IP 排序），由调度器在主检查后更新
 	quality          string
 	playable         bool
 	bitrateStability string
@@ -122,8 +153,7 @@ type StreamChecker struct {
 	consecutiveFails int
 
 	// 网络指标
-	// 注意：connect_latency_ms 已移除，语义与 response_ms 重复
-	// response_ms: HTTP 响应头返回时间（在 response 字段中）
+	// response_ms: HTTP 响应头返回时间（在 response 字段中），DNS / TCP / TLS 等分阶段耗时在 transport.HTTP 中
 	ttfbMs            float64 // 首字节时间（ms），从请求开始到第一个数据包读取的时间
 	readThroughputBps float64 // 读取吞吐（bps）
 	readStallCount    int64   // 读阻塞次数
@@ -131,6 +161,9 @@ type StreamChecker struct {
 	readStallTotalMs  float64 // 总阻塞时长（ms）
 	readStallRatio    float64 // 阻塞时间占总采样时长比例（0~1）
 
+	// 协议相关的传输指标（HLS / RTMP / RTSP，其他协议的字段为 0）
+	transport TransportStats
+
 	log *slog.Logger
 }
 
@@ -180,25 +213,44 @@ func extractStreamName(project, id, rawURL string) string {
 }
 
 // NewStreamChecker 创建流检查器
-func NewStreamChecker(id, url, project, line string, labels map[string]string) *StreamChecker {
+// 协议优先使用配置中的 protocol，未配置时按 URL 识别
+func NewStreamChecker(cfg StreamConfig, project, line string, labels map[string]string) (*StreamChecker, error) {
+	protocol, err := resolveProtocol(cfg.Protocol, cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	// 启动时检查 env: / file: 形式的密钥是否可以读取（每次检查时重新读取，密钥文件更新后生效）
+	if _, err := cfg.Request.header(); err != nil {
+		return nil, fmt.Errorf("请求参数无效: %w", err)
+	}
+	if _, err := signURL(cfg.URL, cfg.Sign, time.Now()); err != nil {
+		return nil, fmt.Errorf("签名配置无效: %w", err)
+	}
+
 	return &StreamChecker{
-		id:             id,
-		url:            url,
+		id:             cfg.ID,
+		url:            cfg.URL,
+		protocol:       protocol,
+		allowAudioOnly: cfg.AllowAudioOnly,
+		analyzeFrames:  cfg.AnalyzeFrames,
+		probeAllEdges:  cfg.ProbeAllEdges,
+		request:        cfg.Request,
+		sign:           cfg.Sign,
 		project:        project,
 		line:           line,
 		labels:         labels,
-		name:           extractStreamName(project, id, url),
+		name:           extractStreamName(project, cfg.ID, cfg.URL),
 		healthy:        false,
 		playable:       false,
 		quality:        "unknown",
 		bitrateHistory: make([]float64, 0, 10),
 		log:            GetLogger(),
-	}
+	}, nil
 }
 
 // Check 执行一次流检查
-func (sc *StreamChecker) Check(timeout time.Duration) error {
-	sc.log.Debug("开始检查流", "流ID", sc.id, "URL", sc.url, "超时", timeout)
+func (sc *StreamChecker) Check(timeout time.Duration) (err error) {
+	sc.log.Debug("开始检查流", "流ID", sc.id, "URL", sc.url, "协议", sc.protocol, "超时", timeout)
 
 	startTime := time.Now()
 
@@ -208,32 +260,16 @@ func (sc *StreamChecker) Check(timeout time.Duration) error {
 	// 使用 context.WithTimeout 控制超时
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-
-	// 记录请求开始时间，用于计算HTTP-FLV请求响应时间
-	reqStart := time.Now()
-	req, err := http.NewRequestWithContext(ctx, "GET", sc.url, nil)
-	if err != nil {
-		return fmt.Errorf("创建请求失败: %w", err)
+	if sc.edgeIP != "" {
+		ctx = withEdgeTarget(ctx, sc.url, sc.edgeIP)
 	}
 
-	// 使用全局HTTP客户端，复用连接池（context 超时会自动取消）
-	resp, err := globalHTTPClient.Do(req)
-	responseHeaderTime := time.Since(reqStart) // HTTP 响应头返回时间
+	// 请求头、User-Agent、Referer、Cookie（request 配置）
+	header, err := sc.request.header()
 	if err != nil {
-		// 检查是否是超时错误
-		if ctx.Err() == context.DeadlineExceeded {
-			return fmt.Errorf("请求超时: %w", err)
-		}
-		return fmt.Errorf("连接失败: %w", err)
+		return fmt.Errorf("读取请求参数失败: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
-	}
-
-	// HTTP 响应头返回时间（ms）
-	responseTime := responseHeaderTime.Milliseconds()
+	ctx = withRequestHeader(ctx, header)
 
 	// 网络指标统计变量
 	var (
@@ -244,9 +280,8 @@ func (sc *StreamChecker) Check(timeout time.Duration) error {
 		firstReadTime time.Time
 	)
 
-	// 创建包装的 Reader 用于统计读取阻塞和吞吐
+	// 创建包装的 Reader 用于统计读取阻塞和吞吐（由 Prober 设置实际的 reader）
 	trackingReader := &stallTrackingReader{
-		reader:         resp.Body,
 		totalBytes:     &totalBytes,
 		stallCount:     &stallCount,
 		maxStall:       &maxStall,
@@ -255,114 +290,103 @@ func (sc *StreamChecker) Check(timeout time.Duration) error {
 		stallThreshold: getStallThreshold(),
 	}
 
-	// 创建解复用器（使用包装的 Reader）
-	demuxer := flv.NewDemuxer(trackingReader)
-
-	// joy5 不需要预先获取流信息，直接读取包即可
-	hasVideo := false
-	hasMetadata := false
-
-	// 采样数据包 - 基于时间采样，更真实
-	packetCount := 0
-	videoCount := 0
-	audioCount := 0
-	keyframeCount := 0
-
-	// 从配置读取采样参数，如果未配置则使用默认值
-	sampleDurationSec := 10
-	minKeyframes := 2
-	if globalConfig != nil {
-		if globalConfig.Exporter.SampleDuration > 0 {
-			sampleDurationSec = globalConfig.Exporter.SampleDuration
-		}
-		if globalConfig.Exporter.MinKeyframes > 0 {
-			minKeyframes = globalConfig.Exporter.MinKeyframes
-		}
+	// CDN 防盗链签名：每次检查用当前时间重新生成签名地址（sc.url 保持不带签名，用于日志和标签）
+	streamURL, err := signURL(sc.url, sc.sign, startTime)
+	if err != nil {
+		return fmt.Errorf("生成签名地址失败: %w", err)
 	}
-	sampleDuration := time.Duration(sampleDurationSec) * time.Second
-	sampleStartTime := time.Now()
-
-	// 用于延迟计算的变量
-	firstPacketTime := time.Time{} // 第一个视频包到达的系统时间（用于是否读到包的判定）
-	firstDTS := int64(0)           // 第一个视频包的DTS
-	lastDTS := int64(0)            // 最后一个视频包的DTS
-	keyframeInterval := 0
-
-	for {
-		// 基于时间的采样，提前退出条件：达到采样时间且收集到足够关键帧
-		elapsed := time.Since(sampleStartTime)
-		if elapsed >= sampleDuration && keyframeCount >= minKeyframes {
-			break
-		}
 
-		// 如果已经超过采样时间，即使关键帧不够也退出（避免长时间阻塞）
-		if elapsed >= sampleDuration*2 {
-			break
-		}
-
-		pktRecvTime := time.Now() // 记录包到达时间
-		pkt, err := demuxer.ReadPacket()
-		if err != nil {
-			if err == io.EOF {
-				break
+	prober, err := newProber(sc.protocol, streamURL, trackingReader)
+	if err != nil {
+		return err
+	}
+	defer prober.Close()
+
+	// 记录实际连接的对端 IP 和证书信息（失败时也记录，便于定位出问题的边缘节点和过期的证书）
+	defer func() {
+		transport := prober.TransportStats()
+		sc.mu.Lock()
+		sc.remoteIP = transport.RemoteIP
+		sc.tls = transport.TLS
+		sc.mu.Unlock()
+	}()
+
+	// 请求开始时间，用于计算 TTFB（在 Open 之前设置，采样记录中的 TTFB 使用同一个起点）
+	var reqStart time.Time
+
+	// 保存失败采样：记录读取的原始字节，检查结束后由调度器决定是否写入磁盘（边缘节点子检查不保存）
+	if getCaptureDir() != "" && sc.edgeIP == "" {
+		trackingReader.capture = &captureBuffer{limit: getCaptureMaxBytes()}
+		defer func() {
+			record := captureRecord{
+				Stream:           sc.Key(),
+				URL:              sc.url,
+				Protocol:         sc.protocol,
+				RemoteIP:         prober.TransportStats().RemoteIP,
+				StartTime:        startTime,
+				DurationMs:       time.Since(startTime).Seconds() * 1000,
+				ResponseMs:       prober.TransportStats().ResponseTime.Seconds() * 1000,
+				TotalBytes:       totalBytes,
+				CapturedBytes:    len(trackingReader.capture.data),
+				Truncated:        trackingReader.capture.truncated,
+				ReadStallCount:   stallCount,
+				ReadStallMaxMs:   maxStall.Seconds() * 1000,
+				ReadStallTotalMs: totalStall.Seconds() * 1000,
 			}
-			return fmt.Errorf("读取数据包失败: %w", err)
-		}
-
-		packetCount++
-		// totalBytes 由 trackingReader 统计，这里不再累加
-
-		// 检查 metadata（只在第一次收到时记录，减少日志输出）
-		if pkt.Type == av.Metadata && !hasMetadata {
-			hasMetadata = true
-		}
-
-		// joy5: 使用 Type 判断包类型
-		switch pkt.Type {
-		case av.H264:
-			videoCount++
-			hasVideo = true
-
-			if pkt.IsKeyFrame {
-				keyframeCount++
+			if !firstReadTime.IsZero() && !reqStart.IsZero() {
+				record.TTFBMs = firstReadTime.Sub(reqStart).Seconds() * 1000
 			}
+			sc.mu.Lock()
+			sc.capture = &sampleCapture{buffer: trackingReader.capture, record: record}
+			sc.mu.Unlock()
+		}()
+	}
 
-			// 记录时间戳和到达时间
-			if firstPacketTime.IsZero() {
-				firstPacketTime = pktRecvTime
-				firstDTS = int64(pkt.Time)
-			}
-			lastDTS = int64(pkt.Time)
+	// 记录请求开始时间，用于计算 TTFB
+	reqStart = time.Now()
+	if err := prober.Open(ctx); err != nil {
+		return err
+	}
 
-			// 获取编码信息（避免频繁加锁，只在第一次设置）
-			if sc.codec == "" {
-				sc.codec = "H264"
-			}
-		case av.AAC:
-			audioCount++
-		}
+	// 采样数据包 - 基于时间采样，更真实
+	sampleDuration, minKeyframes := getSampleParams()
+	sampler := newStreamSampler(sc.allowAudioOnly, sc.log.With("流ID", sc.id), &totalBytes)
+	if p, ok := prober.(segmentedProber); ok {
+		sampler.setSegmented(p.segmented())
+	}
+	if p, ok := prober.(avSyncProber); ok {
+		sampler.skipAVSync = !p.avAligned()
+	}
+	if p, ok := prober.(ptsProber); ok {
+		sampler.videoDTS.pts = p.videoPTS()
+	}
+	if err := sampler.run(prober, sampleDuration, minKeyframes); err != nil {
+		return err
 	}
 
-	if !hasVideo {
+	// 纯音频流：配置了 allow_audio_only 且有音频包时按音频判定
+	audioOnly := sampler.videoCount == 0
+	if audioOnly && (!sc.allowAudioOnly || sampler.audioCount == 0) {
+		if sc.allowAudioOnly {
+			return fmt.Errorf("未找到音视频流")
+		}
 		return fmt.Errorf("未找到视频流")
 	}
 
 	duration := time.Since(startTime)
+	transport := prober.TransportStats()
 
-	// 计算 GOP 大小（关键帧间隔的帧数）
-	if keyframeCount > 1 {
-		// 简单方法：总帧数 / 关键帧数
-		keyframeInterval = videoCount / keyframeCount
-	} else if keyframeCount == 1 {
-		// 只有一个关键帧，GOP就是所有帧
-		keyframeInterval = videoCount
-	} else {
-		// 没有关键帧，设为0
-		keyframeInterval = 0
+	// 画面内容分析：解码最后一个关键帧（ffmpeg 子进程，不计入采样耗时，也不持有锁）
+	var gray []byte
+	if sc.analyzeFrames && sampler.keyframe != nil {
+		gray, err = sampler.keyframe.decodeGray(context.Background(), contentFrameSize)
+		if err != nil {
+			sc.log.Debug("关键帧解码失败", "流ID", sc.id, "编码", sampler.keyframe.Codec, "错误", err)
+		}
 	}
 
 	// 计算网络指标
-	// response_ms: HTTP 响应头返回时间（已在上面计算）
+	// response_ms: 请求响应时间（由 Prober 提供，各协议含义不同）
 	// ttfb_ms: 首字节时间（第一个数据包读取时间）
 	ttfbMs := 0.0
 	if !firstReadTime.IsZero() {
@@ -391,18 +415,42 @@ func (sc *StreamChecker) Check(timeout time.Duration) error {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
-	sc.totalPackets = int64(packetCount)
-	sc.videoPackets = int64(videoCount)
-	sc.audioPackets = int64(audioCount)
-	sc.keyframes = int64(keyframeCount)
+	sc.totalPackets = int64(sampler.packetCount)
+	sc.videoPackets = int64(sampler.videoCount)
+	sc.audioPackets = int64(sampler.audioCount)
+	sc.keyframes = int64(sampler.keyframeCount)
+	sc.codec = sampler.codec
+	sc.videoInfo = sampler.videoInfo
+	sc.audio = sampler.audio.stats(sampler.audioInfo)
+	sc.audioOnly = audioOnly
+	sc.startup = sampler.startupStats(reqStart)
+	sc.timestamps = sampler.timestampStats()
+	sc.avSync = sampler.avSync.stats()
+	sc.intervals = sampler.intervals
+	sc.latency = sampler.latency.stats(sampler.burst.steady())
+	sc.burst = sampler.burst.stats()
+	sc.metadata = sampler.metadata
+	sc.frozen = sampler.frozen.stats(sc.frozenHistory, getFrozenMinDuration())
+	sc.updateFrozenHistory()
+	if sampler.keyframe != nil {
+		sc.keyframe = sampler.keyframe
+	}
+	if gray != nil {
+		// 与上一次检查的关键帧比较感知哈希
+		sc.content = analyzeContent(gray, sc.content.hash, sc.content.Analyzed)
+	} else {
+		sc.content = ContentStats{}
+	}
+	sc.width = sampler.videoInfo.Width
+	sc.height = sampler.videoInfo.Height
 	sc.lastCheckTime = time.Now()
 	sc.healthy = true
 	sc.consecutiveFails = 0
-	sc.gopSize = keyframeInterval
-	sc.response = responseTime // 更新响应时间
+	sc.gopSize = sampler.gopSize()
+	sc.gop = sampler.gopStats()
+	sc.response = transport.ResponseTime.Milliseconds() // 更新响应时间
 
 	// 更新网络指标
-	// 注意：connect_latency_ms 已移除，使用 response_ms（HTTP 响应头返回时间）和 ttfb_ms（首字节时间）即可
 	sc.ttfbMs = ttfbMs
 	sc.readThroughputBps = readThroughputBps
 	sc.readStallCount = stallCount
@@ -410,86 +458,40 @@ func (sc *StreamChecker) Check(timeout time.Duration) error {
 	sc.readStallTotalMs = totalStall.Seconds() * 1000
 	sc.readStallRatio = readStallRatio
 
+	// 更新协议相关的传输指标（其他协议的字段保持为 0）
+	sc.transport = transport
+
 	// 计算帧率和码率（基于 DTS 时间，更准确）
-	if !firstPacketTime.IsZero() && lastDTS > firstDTS {
-		dtsElapsed := float64(lastDTS-firstDTS) / 1e9 // 纳秒转秒
-		if dtsElapsed > 0 {
-			sc.framerate = float64(videoCount) / dtsElapsed
-			// 基于 DTS 时间计算码率更准确
-			sc.currentBitrate = (float64(totalBytes) * 8) / dtsElapsed // bps
-		}
+	if dtsElapsed := sampler.dtsElapsed(); dtsElapsed > 0 {
+		sc.framerate = float64(sampler.videoCount) / dtsElapsed
+		// 基于 DTS 时间计算码率更准确
+		sc.currentBitrate = (float64(totalBytes) * 8) / dtsElapsed // bps
 	} else if duration.Seconds() > 0 {
 		// 如果没有 DTS，使用实际耗时
 		sc.currentBitrate = (float64(totalBytes) * 8) / duration.Seconds() // bps
 	}
 
-	// 更新码率历史（优化：减少计算频率）
-	if sc.currentBitrate > 0 {
-		sc.bitrateHistory = append(sc.bitrateHistory, sc.currentBitrate)
-		historyLen := len(sc.bitrateHistory)
-		if historyLen > 10 {
-			sc.bitrateHistory = sc.bitrateHistory[1:]
-			historyLen = 10
-		}
+	// 视频码率：按视频帧负载和视频 DTS 跨度计算（音频码率见 sc.audio）
+	sc.videoBitrate = sampler.videoBitrate()
 
-		// 计算平均码率（优化：使用增量计算）
-		sum := 0.0
-		for i := 0; i < historyLen; i++ {
-			sum += sc.bitrateHistory[i]
-		}
-		sc.avgBitrate = sum / float64(historyLen)
-
-		// 评估码率稳定性（只在有足够数据时计算）
-		if historyLen >= 3 {
-			// 优化：使用单次遍历计算方差
-			variance := 0.0
-			for i := 0; i < historyLen; i++ {
-				diff := sc.bitrateHistory[i] - sc.avgBitrate
-				variance += diff * diff
-			}
-			variance /= float64(historyLen)
-			stdDev := math.Sqrt(variance)
-
-			// 计算变异系数（CV = 标准差/平均值）
-			if sc.avgBitrate > 0 {
-				cv := stdDev / sc.avgBitrate
-				// 根据变异系数评估稳定性
-				// CV < 0.15 (15%) = 稳定
-				// CV < 0.30 (30%) = 中等
-				// CV >= 0.30 = 不稳定
-				if cv < 0.15 {
-					sc.bitrateStability = "stable"
-				} else if cv < 0.30 {
-					sc.bitrateStability = "moderate"
-				} else {
-					sc.bitrateStability = "unstable"
-				}
-			} else {
-				sc.bitrateStability = "unknown"
-			}
-		} else {
-			sc.bitrateStability = "unknown"
-		}
-	}
+	// onMetaData 声明的帧率 / 码率与实测值比较
+	sc.metadataMismatch = compareMetadata(sc.metadata, sc.framerate, sc.videoBitrate, sc.audio.BitrateBps, getMetadataMismatchPercent())
 
-	// 此处延迟已定义为 HTTP-FLV 请求响应时间（在完成HTTP响应后已设置）
-
-	// 评估质量
-	sc.playable = keyframeCount >= 2 && videoCount > 10
-	if sc.playable {
-		// 质量评估：基于帧率、码率和稳定性
-		if sc.framerate >= 25 && sc.currentBitrate >= 600000 {
-			// 高质量：帧率>=25fps，码率>=600kbps
-			sc.quality = "good"
-		} else if sc.framerate >= 20 && sc.currentBitrate >= 400000 {
-			// 中等质量：帧率>=20fps，码率>=400kbps
-			sc.quality = "fair"
-		} else {
-			// 低质量
-			sc.quality = "poor"
-		}
+	sc.updateBitrateHistory()
+
+	// 评估质量（纯音频流按音频帧数和断流评估）
+	if audioOnly {
+		sc.playable = sampler.audioOnlyPlayable()
+		sc.quality = evaluateAudioQuality(sc.playable, sc.audio.Gaps)
 	} else {
-		sc.quality = "poor"
+		sc.playable = sampler.playable()
+		sc.quality = evaluateQuality(sc.playable, sc.framerate, sc.currentBitrate)
+		// 音画不同步时质量降一级
+		sc.quality = evaluateAVSyncQuality(sc.quality, sc.avSync, getAVSyncThreshold())
+		// 疑似画面静止时质量为 poor
+		sc.quality = evaluateFrozenQuality(sc.quality, sc.frozen)
+		// 解码分析发现黑屏、纯色画面或画面静止时质量为 poor
+		sc.quality = evaluateContentQuality(sc.quality, sc.content)
 	}
 
 	// 注意：这里已经持有 mu.Lock()，不需要再加锁
@@ -499,18 +501,119 @@ func (sc *StreamChecker) Check(timeout time.Duration) error {
 		"可播放", sc.playable,
 		"质量", sc.quality,
 		"请求响应ms", sc.response,
-		"视频包", videoCount,
-		"关键帧", keyframeCount,
+		"视频包", sampler.videoCount,
+		"音频包", sampler.audioCount,
+		"纯音频", audioOnly,
+		"关键帧", sampler.keyframeCount,
 		"码率kbps", fmt.Sprintf("%.1f", sc.currentBitrate/1000),
+		"视频码率kbps", fmt.Sprintf("%.1f", sc.videoBitrate/1000),
 		"平均码率kbps", fmt.Sprintf("%.1f", sc.avgBitrate/1000),
 		"稳定性", sc.bitrateStability,
 		"帧率fps", fmt.Sprintf("%.1f", sc.framerate),
 		"GOP帧", sc.gopSize,
-		"编码", sc.codec)
+		"GOP时长s", fmt.Sprintf("%.2f", sc.gop.DurationMeanSec),
+		"最长GOPs", fmt.Sprintf("%.2f", sc.gop.DurationMaxSec),
+		"GOP超标", sc.gop.ExceedsMax,
+		"编码", sc.codec,
+		"首关键帧ms", fmt.Sprintf("%.0f", sc.startup.FirstKeyframeMs),
+		"视频DTS跳变", sc.timestamps.VideoDiscontinuities,
+		"视频DTS回退", sc.timestamps.VideoBackward,
+		"音频DTS跳变", sc.timestamps.AudioDiscontinuities,
+		"音频DTS回退", sc.timestamps.AudioBackward,
+		"音画偏移ms", fmt.Sprintf("%.1f", sc.avSync.OffsetMs),
+		"音画漂移ms每秒", fmt.Sprintf("%.2f", sc.avSync.DriftMsPerSec),
+		"时钟漂移ms每秒", fmt.Sprintf("%.2f", sc.latency.ClockDriftMsPerSec),
+		"端到端延迟ms", fmt.Sprintf("%.0f", sc.latency.E2ELatencyMs),
+		"采集时间来源", sc.latency.CaptureTimeSource,
+		"GOP缓存突发", sc.burst.Detected,
+		"突发KB", sc.burst.Bytes/1024,
+		"突发媒体ms", fmt.Sprintf("%.0f", sc.burst.MediaMs),
+		"稳态码率kbps", fmt.Sprintf("%.1f", sc.burst.SteadyBitrateBps/1000),
+		"编码器", sc.metadata.Encoder,
+		"声明帧率", sc.metadata.Framerate,
+		"声明视频码率kbps", sc.metadata.VideoDataRateKbps,
+		"帧率不符", sc.metadataMismatch.Framerate,
+		"视频码率不符", sc.metadataMismatch.VideoBitrate,
+		"疑似静止", sc.frozen.Suspected,
+		"静止原因", sc.frozen.Reason,
+		"最长空帧s", fmt.Sprintf("%.2f", sc.frozen.TinyRunSec),
+		"非关键帧中位数B", sc.frozen.InterFrameMedianBytes,
+		"画面已分析", sc.content.Analyzed,
+		"黑色像素占比", fmt.Sprintf("%.2f", sc.content.BlackRatio),
+		"亮度方差", fmt.Sprintf("%.1f", sc.content.LumaVariance),
+		"关键帧相似度", fmt.Sprintf("%.3f", sc.content.Similarity),
+		"音频码率kbps", fmt.Sprintf("%.1f", sc.audio.BitrateBps/1000),
+		"音频断流", sc.audio.Gaps)
 
 	return nil
 }
 
+// updateBitrateHistory 记录本次码率，更新平均码率和码率稳定性（调用方需持有锁）
+func (sc *StreamChecker) updateBitrateHistory() {
+	if sc.currentBitrate <= 0 {
+		return
+	}
+
+	sc.bitrateHistory = append(sc.bitrateHistory, sc.currentBitrate)
+	historyLen := len(sc.bitrateHistory)
+	if historyLen > 10 {
+		sc.bitrateHistory = sc.bitrateHistory[1:]
+		historyLen = 10
+	}
+
+	// 计算平均码率
+	sum := 0.0
+	for i := 0; i < historyLen; i++ {
+		sum += sc.bitrateHistory[i]
+	}
+	sc.avgBitrate = sum / float64(historyLen)
+
+	// 评估码率稳定性（只在有足够数据时计算）
+	if historyLen < 3 {
+		sc.bitrateStability = "unknown"
+		return
+	}
+
+	// 优化：使用单次遍历计算方差
+	variance := 0.0
+	for i := 0; i < historyLen; i++ {
+		diff := sc.bitrateHistory[i] - sc.avgBitrate
+		variance += diff * diff
+	}
+	variance /= float64(historyLen)
+	stdDev := math.Sqrt(variance)
+
+	// 计算变异系数（CV = 标准差/平均值）
+	if sc.avgBitrate <= 0 {
+		sc.bitrateStability = "unknown"
+		return
+	}
+	cv := stdDev / sc.avgBitrate
+	// 根据变异系数评估稳定性
+	// CV < 0.15 (15%) = 稳定
+	// CV < 0.30 (30%) = 中等
+	// CV >= 0.30 = 不稳定
+	if cv < 0.15 {
+		sc.bitrateStability = "stable"
+	} else if cv < 0.30 {
+		sc.bitrateStability = "moderate"
+	} else {
+		sc.bitrateStability = "unstable"
+	}
+}
+
+// updateFrozenHistory 记录本次非关键帧大小中位数（调用方需持有锁）
+// 疑似静止的检查不计入，避免画面长时间静止后基线被拉低
+func (sc *StreamChecker) updateFrozenHistory() {
+	if sc.frozen.Suspected || sc.frozen.InterFrameMedianBytes <= 0 {
+		return
+	}
+	sc.frozenHistory = append(sc.frozenHistory, sc.frozen.InterFrameMedianBytes)
+	if len(sc.frozenHistory) > frozenHistorySize {
+		sc.frozenHistory = sc.frozenHistory[1:]
+	}
+}
+
 // MarkFailed 标记检查失败
 func (sc *StreamChecker) MarkFailed() {
 	sc.mu.Lock()
@@ -525,12 +628,27 @@ func (sc *StreamChecker) MarkFailed() {
 	sc.keyframes = 0
 	sc.currentBitrate = 0
 	sc.avgBitrate = 0
+	sc.videoBitrate = 0
 	sc.framerate = 0
 	sc.codec = ""
 	sc.response = 0
 	sc.gopSize = 0
+	sc.gop = GOPStats{}
 	sc.width = 0
 	sc.height = 0
+	sc.videoInfo = VideoInfo{}
+	sc.audio = AudioStats{}
+	sc.audioOnly = false
+	sc.startup = StartupStats{}
+	sc.timestamps = TimestampStats{}
+	sc.avSync = AVSyncStats{}
+	sc.intervals = FrameIntervals{}
+	sc.latency = LatencyStats{}
+	sc.burst = BurstStats{}
+	sc.metadata = StreamMetadata{}
+	sc.metadataMismatch = MetadataMismatch{}
+	sc.frozen = FrozenStats{}
+	sc.content = ContentStats{}
 	sc.quality = "poor"
 	sc.bitrateStability = "unstable"
 	sc.lastCheckTime = time.Now()
@@ -542,6 +660,28 @@ func (sc *StreamChecker) MarkFailed() {
 	sc.readStallMaxMs = 0
 	sc.readStallTotalMs = 0
 	sc.readStallRatio = 0
+
+	sc.transport = TransportStats{}
+}
+
+// Key 流的唯一标识 "project:line:id"（与 Prometheus 标签一致），用于快照等 HTTP 接口
+func (sc *StreamChecker) Key() string {
+	return sc.project + ":" + sc.line + ":" + sc.id
+}
+
+// Snapshot 获取最后一次采样到的关键帧，还没有采样到时返回 nil
+func (sc *StreamChecker) Snapshot() *Keyframe {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.keyframe
+}
+
+// connectLatencyMs TCP 连接耗时：RTMP 取握手前拨号的耗时，HTTP-FLV / HLS 取 httptrace 的结果（调用方持有锁）
+func (sc *StreamChecker) connectLatencyMs() float64 {
+	if sc.protocol == "rtmp" {
+		return sc.transport.RTMP.TCPConnectMs
+	}
+	return sc.transport.HTTP.TCPConnectMs
 }
 
 // GetMetrics 获取指标
@@ -555,6 +695,12 @@ func (sc *StreamChecker) GetMetrics() StreamMetrics {
 		labelsCopy[k] = v
 	}
 
+	// 各边缘节点的检查结果（子检查器有各自的锁）
+	var edges []StreamMetrics
+	for _, edge := range sc.edges {
+		edges = append(edges, edge.GetMetrics())
+	}
+
 	return StreamMetrics{
 		ID:               sc.id,
 		URL:              sc.url,
@@ -568,12 +714,27 @@ func (sc *StreamChecker) GetMetrics() StreamMetrics {
 		Keyframes:        sc.keyframes,
 		CurrentBitrate:   sc.currentBitrate,
 		AvgBitrate:       sc.avgBitrate,
+		VideoBitrate:     sc.videoBitrate,
 		Framerate:        sc.framerate,
 		Codec:            sc.codec,
 		Response:         sc.response,
 		GOPSize:          sc.gopSize,
+		GOP:              sc.gop,
 		Width:            sc.width,
 		Height:           sc.height,
+		VideoInfo:        sc.videoInfo,
+		Audio:            sc.audio,
+		AudioOnly:        sc.audioOnly,
+		Startup:          sc.startup,
+		Timestamps:       sc.timestamps,
+		AVSync:           sc.avSync,
+		FrameIntervals:   sc.intervals,
+		Latency:          sc.latency,
+		Burst:            sc.burst,
+		Metadata:         sc.metadata,
+		MetadataMismatch: sc.metadataMismatch,
+		Frozen:           sc.frozen,
+		Content:          sc.content,
 		Quality:          sc.quality,
 		Playable:         sc.playable,
 		BitrateStability: sc.bitrateStability,
@@ -582,13 +743,21 @@ func (sc *StreamChecker) GetMetrics() StreamMetrics {
 		ConsecutiveFails: sc.consecutiveFails,
 
 		// 网络指标
-		// 注意：ConnectLatencyMs 已移除，使用 Response（HTTP 响应头返回时间）和 TTFBMs（首字节时间）即可
+		ConnectLatencyMs:  sc.connectLatencyMs(),
 		TTFBMs:            sc.ttfbMs,
 		ReadThroughputBps: sc.readThroughputBps,
 		ReadStallCount:    sc.readStallCount,
 		ReadStallMaxMs:    sc.readStallMaxMs,
 		ReadStallTotalMs:  sc.readStallTotalMs,
 		ReadStallRatio:    sc.readStallRatio,
+
+		// 协议相关的传输指标
+		Protocol:  sc.protocol,
+		Transport: sc.transport,
+		RemoteIP:  sc.remoteIP,
+		TLS:       sc.tls,
+		EdgeIP:    sc.edgeIP,
+		Edges:     edges,
 	}
 }
 
@@ -606,12 +775,27 @@ type StreamMetrics struct {
 	Keyframes        int64
 	CurrentBitrate   float64
 	AvgBitrate       float64
+	VideoBitrate     float64 // 视频码率（按视频帧负载计算）
 	Framerate        float64
 	Codec            string
 	Response         int64
 	GOPSize          int
+	GOP              GOPStats // 完整 GOP 的帧数和时长统计
 	Width            int
 	Height           int
+	VideoInfo        VideoInfo        // 从 SPS 解析的视频参数
+	Audio            AudioStats       // 音频参数、码率、抖动和断流
+	AudioOnly        bool             // 是否为纯音频流（需配置 allow_audio_only）
+	Startup          StartupStats     // 起播耗时
+	Timestamps       TimestampStats   // DTS 跳变和回退次数（导出为计数器）
+	AVSync           AVSyncStats      // 音画偏移和漂移速率
+	FrameIntervals   FrameIntervals   // 帧间隔样本（导出为直方图，样本切片只读）
+	Latency          LatencyStats     // 时钟漂移和端到端延迟
+	Burst            BurstStats       // 初始突发（GOP 缓存）和稳态吞吐 / 码率
+	Metadata         StreamMetadata   // onMetaData 中声明的编码参数
+	MetadataMismatch MetadataMismatch // 声明值与实测值的偏差
+	Frozen           FrozenStats      // 基于帧大小的画面静止判定
+	Content          ContentStats     // 解码关键帧的画面内容分析
 	Quality          string
 	Playable         bool
 	BitrateStability string
@@ -620,11 +804,21 @@ type StreamMetrics struct {
 	ConsecutiveFails int
 
 	// 网络指标
-	ConnectLatencyMs  float64 // 连接建立耗时（ms）
+	ConnectLatencyMs  float64 // TCP 连接耗时（ms）：HTTP-FLV / HLS 来自 httptrace，RTMP 来自握手前的拨号，复用连接时为 0
 	TTFBMs            float64 // 首字节时间（ms）
 	ReadThroughputBps float64 // 读取吞吐（bps）
 	ReadStallCount    int64   // 读阻塞次数
 	ReadStallMaxMs    float64 // 最长阻塞时长（ms）
 	ReadStallTotalMs  float64 // 总阻塞时长（ms）
 	ReadStallRatio    float64 // 阻塞时间占总采样时长比例（0~1）
+
+	// 协议相关的传输指标
+	Protocol  string         // 拉流协议（flv / hls / rtmp / rtsp）
+	Transport TransportStats // HLS / RTMP / RTSP 专属指标，其他协议为 0
+	RemoteIP  string         // 最后一次检查实际连接的对端 IP（失败时为失败前连接的 IP）
+	TLS       TLSInfo        // 最后一次检查的对端证书和 TLS 握手信息（失败时为失败前拿到的证书）
+
+	// 边缘节点（probe_all_edges）
+	EdgeIP string          // 子检查连接的边缘节点 IP，主检查为空
+	Edges  []StreamMetrics // 各边缘节点的检查结果（按 IP 排序），未开启时为空
 }
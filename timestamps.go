@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// getDTSJumpThreshold 获取时间戳跳变阈值（从配置读取，默认1000ms）
+func getDTSJumpThreshold() time.Duration {
+	if globalConfig != nil && globalConfig.Exporter.DTSJumpThresholdMs > 0 {
+		return time.Duration(globalConfig.Exporter.DTSJumpThresholdMs) * time.Millisecond
+	}
+	return 1000 * time.Millisecond // 默认值
+}
+
+// TimestampStats 时间戳连续性统计（本次检查的值）
+// 编码器重启、推流切换时 DTS 常会归零或大幅跳变，播放器会因此卡顿或丢帧
+type TimestampStats struct {
+	VideoDiscontinuities int // 相邻视频包 DTS 向前跳变超过阈值的次数
+	VideoBackward        int // 相邻视频包 DTS 回退的次数
+	AudioDiscontinuities int // 相邻音频包 DTS 向前跳变超过阈值的次数
+	AudioBackward        int // 相邻音频包 DTS 回退的次数
+}
+
+// dtsTracker 跟踪单个轨道相邻包的 DTS 间隔，统计跳变和回退
+type dtsTracker struct {
+	track     string // video / audio（日志用）
+	threshold time.Duration
+	log       *slog.Logger
+	// pts 时间戳为 PTS（RTSP）：B 帧重排序造成的小幅回退不计为回退，只统计超过跳变阈值的回退
+	pts bool
+
+	started         bool
+	lastDTS         time.Duration
+	discontinuities int
+	backward        int
+}
+
+// observe 记录一个包的 DTS，跳变和回退在 debug 级别输出相邻 DTS 和间隔
+func (t *dtsTracker) observe(dts time.Duration) {
+	if !t.started {
+		t.started = true
+		t.lastDTS = dts
+		return
+	}
+
+	delta := dts - t.lastDTS
+	switch {
+	case delta < 0 && (!t.pts || -delta > t.threshold):
+		t.backward++
+		t.log.Debug("DTS 回退",
+			"轨道", t.track,
+			"上一个DTSms", t.lastDTS.Milliseconds(),
+			"DTSms", dts.Milliseconds(),
+			"间隔ms", delta.Milliseconds())
+	case delta > t.threshold:
+		t.discontinuities++
+		t.log.Debug("DTS 跳变",
+			"轨道", t.track,
+			"上一个DTSms", t.lastDTS.Milliseconds(),
+			"DTSms", dts.Milliseconds(),
+			"间隔ms", delta.Milliseconds(),
+			"阈值ms", t.threshold.Milliseconds())
+	}
+	t.lastDTS = dts
+}
+
+// FrameIntervals 本次检查相邻视频包的间隔样本（毫秒），导出为直方图
+// DTS 间隔反映编码端的出帧节奏，到达间隔反映网络和服务端的发送节奏
+type FrameIntervals struct {
+	DTSMs     []float64 // 相邻视频包的 DTS 间隔（不含回退和超过跳变阈值的间隔）
+	ArrivalMs []float64 // 相邻视频包的到达时间间隔
+}
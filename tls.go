@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// TLSInfo 对端证书和 TLS 握手信息（仅 https:// / rtmps://）
+// 证书校验失败（例如过期、域名不匹配）时仍然从错误中取出对端证书，版本和加密套件为空
+type TLSInfo struct {
+	Present       bool      // 是否拿到了对端证书
+	Verified      bool      // 证书链和域名校验是否通过
+	Version       string    // 协商的 TLS 版本（TLS 1.2 / TLS 1.3）
+	CipherSuite   string    // 协商的加密套件
+	Issuer        string    // 叶子证书签发者（CN，没有 CN 时为完整 DN）
+	NotAfter      time.Time // 叶子证书过期时间
+	HostnameMatch bool      // 叶子证书的 SAN 是否匹配 URL 中的域名
+	OCSPStapled   bool      // 服务端是否在握手中附带了 OCSP 响应（OCSP stapling）
+}
+
+// newTLSInfo 从握手结果提取证书和握手信息，host 用于检查 SAN 是否匹配；state 为空时返回零值
+func newTLSInfo(state *tls.ConnectionState, host string) TLSInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return TLSInfo{}
+	}
+	info := leafCertInfo(state.PeerCertificates[0], host)
+	info.Verified = len(state.VerifiedChains) > 0
+	info.Version = tls.VersionName(state.Version)
+	info.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	info.OCSPStapled = len(state.OCSPResponse) > 0
+	return info
+}
+
+// tlsInfoFromError 证书校验失败时从错误中取出未通过校验的对端证书，其他错误返回零值
+func tlsInfoFromError(err error, host string) TLSInfo {
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) || len(certErr.UnverifiedCertificates) == 0 {
+		return TLSInfo{}
+	}
+	return leafCertInfo(certErr.UnverifiedCertificates[0], host)
+}
+
+// httpTLSInfo 从 HTTP 请求的结果提取 TLS 信息（请求失败时尝试从证书校验错误中提取）
+func httpTLSInfo(resp *http.Response, err error, host string) TLSInfo {
+	if err != nil {
+		return tlsInfoFromError(err, host)
+	}
+	return newTLSInfo(resp.TLS, host)
+}
+
+// leafCertInfo 叶子证书的签发者、过期时间和 SAN 匹配结果
+func leafCertInfo(cert *x509.Certificate, host string) TLSInfo {
+	issuer := cert.Issuer.CommonName
+	if issuer == "" {
+		issuer = cert.Issuer.String()
+	}
+	return TLSInfo{
+		Present:       true,
+		Issuer:        issuer,
+		NotAfter:      cert.NotAfter,
+		HostnameMatch: cert.VerifyHostname(host) == nil,
+	}
+}
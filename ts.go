@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nareix/joy5/av"
+	"github.com/nareix/joy5/codec/aac"
+	"github.com/nareix/joy5/codec/h264"
+)
+
+// MPEG-TS 相关常量
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+	tsPIDPAT     = 0x0000
+
+	tsStreamTypeAAC  = 0x0f // ADTS AAC
+	tsStreamTypeH264 = 0x1b
+	tsStreamTypeH265 = 0x24
+)
+
+// tsClockRate PES 时间戳时钟频率（90kHz）
+const tsClockRate = 90000
+
+// tsPESBuffer 单个 PID 上正在拼装的 PES 包
+type tsPESBuffer struct {
+	streamType uint8
+	data       []byte
+	started    bool
+
+	// 时间戳回绕展开（每个 PID 独立，避免一路流的回绕影响另一路）
+	lastRawTime int64 // 上一个原始 33 位时间戳（90kHz）
+	timeBase    int64 // 回绕补偿
+	hasLastTime bool
+}
+
+// tsDemuxer 简单的 MPEG-TS 解复用器
+// 只处理 PAT/PMT 和 H.264 / H.265 / AAC(ADTS) 基本流，输出与 FLV 解复用器一致的 av.Packet：
+//   - H.264 数据转换为 AVCC（长度前缀）格式，首次遇到 SPS/PPS 时输出 H264DecoderConfig
+//   - H.265 数据同样转换为 AVCC 格式（pktH265），参数集保留在关键帧中由采样器解析
+//   - AAC 按 ADTS 帧拆分为裸 AAC 帧，首次遇到时输出 AACDecoderConfig
+//
+// 时间戳按 PID 在多个分片之间连续展开（处理 33 位回绕），单位与 joy5 一致（time.Duration）
+type tsDemuxer struct {
+	pmtPID  int
+	streams map[int]*tsPESBuffer // PID -> PES 缓冲
+	pids    []int                // 已登记的 PID（升序），分片结束时按此顺序输出
+
+	h264Codec  *h264.Codec
+	h264Config []byte
+	aacConfig  *aac.MPEG4AudioConfig
+
+	pending []av.Packet
+}
+
+// newTSDemuxer 创建 TS 解复用器
+func newTSDemuxer() *tsDemuxer {
+	return &tsDemuxer{
+		pmtPID:  -1,
+		streams: make(map[int]*tsPESBuffer),
+	}
+}
+
+// Feed 输入一个完整的 TS 分片数据，解析出的包追加到待读取队列
+func (d *tsDemuxer) Feed(data []byte) error {
+	if len(data) < tsPacketSize {
+		return fmt.Errorf("TS 数据过短: %d 字节", len(data))
+	}
+
+	for off := 0; off+tsPacketSize <= len(data); off += tsPacketSize {
+		pkt := data[off : off+tsPacketSize]
+		if pkt[0] != tsSyncByte {
+			return fmt.Errorf("TS 同步字节错误: 偏移 %d", off)
+		}
+		if err := d.parsePacket(pkt); err != nil {
+			return err
+		}
+	}
+
+	// 分片结束时输出所有未完成的 PES（视频 PES 长度通常为 0，只能靠下一个 PUSI 或分片结束判断）
+	d.Flush()
+	return nil
+}
+
+// Flush 按 PID 顺序输出所有缓冲中的 PES
+func (d *tsDemuxer) Flush() {
+	for _, pid := range d.pids {
+		d.flushPES(d.streams[pid])
+	}
+}
+
+// Next 取出一个已解析的包
+func (d *tsDemuxer) Next() (av.Packet, bool) {
+	if len(d.pending) == 0 {
+		return av.Packet{}, false
+	}
+	pkt := d.pending[0]
+	d.pending = d.pending[1:]
+	return pkt, true
+}
+
+// parsePacket 解析一个 188 字节的 TS 包
+func (d *tsDemuxer) parsePacket(pkt []byte) error {
+	pusi := pkt[1]&0x40 != 0
+	pid := int(pkt[1]&0x1f)<<8 | int(pkt[2])
+	afc := (pkt[3] >> 4) & 0x3
+
+	payload := pkt[4:]
+	switch afc {
+	case 0x2: // 只有 adaptation field
+		return nil
+	case 0x3: // adaptation field + payload
+		afLen := int(payload[0])
+		if afLen+1 > len(payload) {
+			return nil
+		}
+		payload = payload[afLen+1:]
+	case 0x0:
+		return nil
+	}
+
+	switch {
+	case pid == tsPIDPAT:
+		d.parsePAT(pusi, payload)
+	case pid == d.pmtPID:
+		d.parsePMT(pusi, payload)
+	default:
+		s, ok := d.streams[pid]
+		if !ok {
+			return nil
+		}
+		if pusi {
+			d.flushPES(s)
+			s.started = true
+		}
+		if s.started {
+			s.data = append(s.data, payload...)
+		}
+	}
+
+	return nil
+}
+
+// psiSection 从 payload 中取出 PSI 段（跳过 pointer_field）
+func psiSection(pusi bool, payload []byte) []byte {
+	if !pusi || len(payload) < 1 {
+		return nil
+	}
+	ptr := int(payload[0])
+	if 1+ptr >= len(payload) {
+		return nil
+	}
+	section := payload[1+ptr:]
+	if len(section) < 3 {
+		return nil
+	}
+	sectionLen := int(section[1]&0x0f)<<8 | int(section[2])
+	if 3+sectionLen > len(section) {
+		return nil
+	}
+	return section[:3+sectionLen]
+}
+
+// parsePAT 解析 PAT，记录第一个节目的 PMT PID
+func (d *tsDemuxer) parsePAT(pusi bool, payload []byte) {
+	section := psiSection(pusi, payload)
+	if len(section) < 12 {
+		return
+	}
+	// 跳过 8 字节表头，去掉末尾 4 字节 CRC
+	entries := section[8 : len(section)-4]
+	for i := 0; i+4 <= len(entries); i += 4 {
+		program := int(entries[i])<<8 | int(entries[i+1])
+		pid := int(entries[i+2]&0x1f)<<8 | int(entries[i+3])
+		if program != 0 {
+			d.pmtPID = pid
+			return
+		}
+	}
+}
+
+// parsePMT 解析 PMT，登记支持的基本流
+func (d *tsDemuxer) parsePMT(pusi bool, payload []byte) {
+	section := psiSection(pusi, payload)
+	if len(section) < 16 {
+		return
+	}
+	programInfoLen := int(section[10]&0x0f)<<8 | int(section[11])
+	pos := 12 + programInfoLen
+	end := len(section) - 4
+	for pos+5 <= end {
+		streamType := section[pos]
+		pid := int(section[pos+1]&0x1f)<<8 | int(section[pos+2])
+		esInfoLen := int(section[pos+3]&0x0f)<<8 | int(section[pos+4])
+		pos += 5 + esInfoLen
+
+		switch streamType {
+		case tsStreamTypeH264, tsStreamTypeH265, tsStreamTypeAAC:
+			if _, ok := d.streams[pid]; !ok {
+				d.streams[pid] = &tsPESBuffer{streamType: streamType}
+				i, _ := slices.BinarySearch(d.pids, pid)
+				d.pids = slices.Insert(d.pids, i, pid)
+			}
+		}
+	}
+}
+
+// readPESTimestamp 读取 5 字节 PTS/DTS 字段
+func readPESTimestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 |
+		int64(b[1])<<22 |
+		int64(b[2]>>1)<<15 |
+		int64(b[3])<<7 |
+		int64(b[4]>>1)
+}
+
+// unwrapTime 展开本 PID 的 33 位时间戳回绕，返回 time.Duration
+func (s *tsPESBuffer) unwrapTime(raw int64) time.Duration {
+	const wrap = int64(1) << 33
+	if s.hasLastTime {
+		diff := raw - s.lastRawTime
+		if diff < -wrap/2 {
+			s.timeBase += wrap
+		} else if diff > wrap/2 {
+			s.timeBase -= wrap
+		}
+	}
+	s.lastRawTime = raw
+	s.hasLastTime = true
+	return time.Duration(s.timeBase+raw) * time.Second / tsClockRate
+}
+
+// flushPES 解析缓冲中的 PES 包并转换为 av.Packet
+func (d *tsDemuxer) flushPES(s *tsPESBuffer) {
+	data := s.data
+	s.data = s.data[:0:0]
+	s.started = false
+
+	if len(data) < 9 || data[0] != 0 || data[1] != 0 || data[2] != 1 {
+		return
+	}
+	headerLen := int(data[8])
+	if 9+headerLen > len(data) {
+		return
+	}
+	flags := data[7] >> 6
+
+	var pts, dts time.Duration
+	hasPTS := false
+	if flags&0x2 != 0 && headerLen >= 5 {
+		pts = s.unwrapTime(readPESTimestamp(data[9:14]))
+		dts = pts
+		hasPTS = true
+	}
+	if flags == 0x3 && headerLen >= 10 {
+		dts = s.unwrapTime(readPESTimestamp(data[14:19]))
+	}
+	if !hasPTS {
+		return
+	}
+
+	payload := data[9+headerLen:]
+	switch s.streamType {
+	case tsStreamTypeH264:
+		d.emitH264(payload, dts, pts)
+	case tsStreamTypeH265:
+		d.emitH265(payload, dts, pts)
+	case tsStreamTypeAAC:
+		d.emitAAC(payload, pts)
+	}
+}
+
+// splitAnnexB 按起始码拆分 Annex-B 字节流
+func splitAnnexB(b []byte) [][]byte {
+	var nalus [][]byte
+	start := -1
+	i := 0
+	for i+2 < len(b) {
+		if b[i] == 0 && b[i+1] == 0 && b[i+2] == 1 {
+			if start >= 0 {
+				end := i
+				// 4 字节起始码的前导 0 属于下一个起始码
+				for end > start && b[end-1] == 0 {
+					end--
+				}
+				if end > start {
+					nalus = append(nalus, b[start:end])
+				}
+			}
+			i += 3
+			start = i
+			continue
+		}
+		i++
+	}
+	if start >= 0 && start < len(b) {
+		nalus = append(nalus, b[start:])
+	}
+	return nalus
+}
+
+// emitH264 将一个 H.264 访问单元转换为 AVCC 包
+func (d *tsDemuxer) emitH264(payload []byte, dts, pts time.Duration) {
+	nalus := splitAnnexB(payload)
+	if len(nalus) == 0 {
+		return
+	}
+
+	isKey := false
+	frame := make([][]byte, 0, len(nalus))
+	for _, nalu := range nalus {
+		switch h264.NALUType(nalu) {
+		case h264.NALU_SPS, h264.NALU_PPS:
+			if d.h264Codec == nil {
+				d.h264Codec = h264.NewCodec()
+			}
+			d.h264Codec.AddSPSPPS(nalu)
+		case h264.NALU_AUD:
+			continue
+		case h264.NALU_IDR:
+			isKey = true
+		}
+		frame = append(frame, nalu)
+	}
+
+	// 首次凑齐 SPS/PPS 时输出解码配置（AVCDecoderConfigurationRecord）
+	if d.h264Config == nil && d.h264Codec != nil && len(d.h264Codec.SPS) > 0 && len(d.h264Codec.PPS) > 0 {
+		d.h264Config = avcDecoderConfig(d.h264Codec)
+		d.pending = append(d.pending, av.Packet{
+			Type: av.H264DecoderConfig,
+			Data: d.h264Config,
+			H264: d.h264Codec,
+		})
+	}
+
+	d.pending = append(d.pending, av.Packet{
+		Type:       av.H264,
+		IsKeyFrame: isKey,
+		Time:       dts,
+		CTime:      pts - dts,
+		Data:       h264.JoinNALUsAVCC(frame),
+	})
+}
+
+// emitH265 将一个 H.265 访问单元转换为 AVCC 包
+func (d *tsDemuxer) emitH265(payload []byte, dts, pts time.Duration) {
+	nalus := splitAnnexB(payload)
+	if len(nalus) == 0 {
+		return
+	}
+
+	isKey := false
+	frame := make([][]byte, 0, len(nalus))
+	for _, nalu := range nalus {
+		if h265NALUType(nalu) == h265NALUAUD {
+			continue
+		}
+		if h265IsIRAP(nalu) {
+			isKey = true
+		}
+		frame = append(frame, nalu)
+	}
+	if len(frame) == 0 {
+		return
+	}
+
+	d.pending = append(d.pending, av.Packet{
+		Type:       pktH265,
+		IsKeyFrame: isKey,
+		Time:       dts,
+		CTime:      pts - dts,
+		Data:       h264.JoinNALUsAVCC(frame),
+	})
+}
+
+// emitAAC 将 ADTS 流拆分为裸 AAC 帧
+func (d *tsDemuxer) emitAAC(payload []byte, pts time.Duration) {
+	t := pts
+	for len(payload) >= aac.ADTSHeaderLength {
+		config, hdrLen, frameLen, samples, err := aac.ParseADTSHeader(payload)
+		if err != nil || frameLen > len(payload) {
+			return
+		}
+
+		if d.aacConfig == nil {
+			cfg := config
+			d.aacConfig = &cfg
+			if pkt, err := aacDecoderConfigPacket(config); err == nil {
+				d.pending = append(d.pending, pkt)
+			}
+		}
+
+		d.pending = append(d.pending, av.Packet{
+			Type: av.AAC,
+			Time: t,
+			Data: payload[hdrLen:frameLen],
+		})
+
+		if config.SampleRate > 0 {
+			t += time.Duration(samples) * time.Second / time.Duration(config.SampleRate)
+		}
+		payload = payload[frameLen:]
+	}
+}
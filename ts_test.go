@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/nareix/joy5/av"
+	"github.com/nareix/joy5/codec/h264"
+)
+
+// 测试用 PID
+const (
+	testPMTPID   = 0x1000
+	testVideoPID = 0x100
+	testAudioPID = 0x101
+)
+
+// testTSWriter 测试用 TS 分片生成器，按 PID 维护 continuity_counter
+type testTSWriter struct {
+	buf bytes.Buffer
+	cc  map[int]byte
+}
+
+func newTestTSWriter() *testTSWriter {
+	return &testTSWriter{cc: make(map[int]byte)}
+}
+
+// packet 写一个 TS 包，payload 不足 184 字节时用 adaptation field 填充
+func (w *testTSWriter) packet(pid int, pusi bool, payload []byte) {
+	hdr := []byte{tsSyncByte, byte(pid>>8) & 0x1f, byte(pid), 0x10 | w.cc[pid]}
+	if pusi {
+		hdr[1] |= 0x40
+	}
+	w.cc[pid] = (w.cc[pid] + 1) & 0x0f
+
+	if stuffing := 184 - len(payload); stuffing > 0 {
+		hdr[3] |= 0x20
+		af := make([]byte, stuffing)
+		af[0] = byte(stuffing - 1)
+		if stuffing > 1 {
+			af[1] = 0x00
+			for i := 2; i < stuffing; i++ {
+				af[i] = 0xff
+			}
+		}
+		hdr = append(hdr, af...)
+	}
+	w.buf.Write(hdr)
+	w.buf.Write(payload)
+}
+
+// pes 把一个 PES 包按 184 字节切分为多个 TS 包
+func (w *testTSWriter) pes(pid int, data []byte) {
+	for first := true; len(data) > 0; first = false {
+		n := min(len(data), 184)
+		w.packet(pid, first, data[:n])
+		data = data[n:]
+	}
+}
+
+// tables 写 PAT（节目 1 -> testPMTPID）和 PMT（给定 PID -> stream_type）
+func (w *testTSWriter) tables(streams ...[2]int) {
+	pat := []byte{0x00, 0xb0, 13, 0x00, 0x01, 0xc1, 0x00, 0x00, 0x00, 0x01, 0xe0 | testPMTPID>>8, testPMTPID & 0xff, 0, 0, 0, 0}
+	w.packet(tsPIDPAT, true, append([]byte{0}, pat...))
+
+	pmt := []byte{0x02, 0xb0, byte(13 + 5*len(streams)), 0x00, 0x01, 0xc1, 0x00, 0x00, 0xe1, 0x00, 0xf0, 0x00}
+	for _, s := range streams {
+		pmt = append(pmt, byte(s[1]), 0xe0|byte(s[0]>>8), byte(s[0]), 0xf0, 0x00)
+	}
+	pmt = append(pmt, 0, 0, 0, 0)
+	w.packet(testPMTPID, true, append([]byte{0}, pmt...))
+}
+
+// testPESTimestamp 编码 5 字节 PTS/DTS 字段
+func testPESTimestamp(prefix byte, ts int64) []byte {
+	return []byte{
+		prefix<<4 | byte(ts>>29)&0x0e | 1,
+		byte(ts >> 22),
+		byte(ts>>14)&0xfe | 1,
+		byte(ts >> 7),
+		byte(ts<<1) | 1,
+	}
+}
+
+// testPES 生成 PES 包，dts < 0 时只带 PTS
+func testPES(streamID byte, pts, dts int64, payload []byte) []byte {
+	b := []byte{0, 0, 1, streamID, 0, 0, 0x80}
+	if dts < 0 {
+		b = append(b, 0x80, 5)
+		b = append(b, testPESTimestamp(0x2, pts)...)
+	} else {
+		b = append(b, 0xc0, 10)
+		b = append(b, testPESTimestamp(0x3, pts)...)
+		b = append(b, testPESTimestamp(0x1, dts)...)
+	}
+	return append(b, payload...)
+}
+
+// testADTSFrame 生成一个 AAC-LC 48kHz 双声道的 ADTS 帧
+func testADTSFrame(payload []byte) []byte {
+	n := 7 + len(payload)
+	hdr := []byte{0xff, 0xf1, 0x4c, 0x80 | byte(n>>11), byte(n >> 3), byte(n<<5) | 0x1f, 0xfc}
+	return append(hdr, payload...)
+}
+
+// testH264AccessUnit 生成 Annex-B 格式的 H.264 访问单元，关键帧带 AUD / SPS / PPS
+func testH264AccessUnit(key bool, size int) []byte {
+	var b []byte
+	startCode := []byte{0, 0, 0, 1}
+	b = append(b, startCode...)
+	b = append(b, 0x09, 0xf0) // AUD
+	if key {
+		sps, _ := base64.StdEncoding.DecodeString(testH264SPS)
+		pps, _ := base64.StdEncoding.DecodeString(testH264PPS)
+		b = append(append(b, startCode...), sps...)
+		b = append(append(b, startCode...), pps...)
+		b = append(b, startCode...)
+		b = append(b, 0x65) // IDR
+	} else {
+		b = append(b, startCode...)
+		b = append(b, 0x41) // non-IDR
+	}
+	return append(b, bytes.Repeat([]byte{0xab}, size)...)
+}
+
+// testTSTime 90kHz 时间戳转 time.Duration
+func testTSTime(ts int64) time.Duration {
+	return time.Duration(ts) * time.Second / tsClockRate
+}
+
+func TestTSDemuxer(t *testing.T) {
+	w := newTestTSWriter()
+	w.tables([2]int{testVideoPID, tsStreamTypeH264}, [2]int{testAudioPID, tsStreamTypeAAC}, [2]int{0x102, 0x06})
+	// 关键帧 PES 超过一个 TS 包，后续包不带 PUSI
+	w.pes(testVideoPID, testPES(0xe0, 9000+3600, 9000, testH264AccessUnit(true, 400)))
+	w.pes(testAudioPID, testPES(0xc0, 9000, -1, append(testADTSFrame([]byte{1, 2, 3}), testADTSFrame([]byte{4, 5})...)))
+	// 未登记的 PID 忽略
+	w.pes(0x102, []byte{0, 0, 1, 0xbd, 0, 0, 0x80, 0, 0})
+	// 下一个 PUSI 结束上一个视频 PES
+	w.pes(testVideoPID, testPES(0xe0, 12600, -1, testH264AccessUnit(false, 10)))
+
+	d := newTSDemuxer()
+	if err := d.Feed(w.buf.Bytes()); err != nil {
+		t.Fatalf("Feed: %v", err)
+	}
+	var pkts []av.Packet
+	for {
+		pkt, ok := d.Next()
+		if !ok {
+			break
+		}
+		pkts = append(pkts, pkt)
+	}
+
+	want := []struct {
+		typ      int
+		time     time.Duration
+		ctime    time.Duration
+		keyframe bool
+		nalus    int
+		data     []byte
+	}{
+		{typ: av.H264DecoderConfig},
+		{typ: av.H264, time: 100 * time.Millisecond, ctime: 40 * time.Millisecond, keyframe: true, nalus: 3},
+		// 分片结束时按 PID 升序输出：视频 0x100 在音频 0x101 之前
+		{typ: av.H264, time: 140 * time.Millisecond, nalus: 1},
+		{typ: av.AACDecoderConfig},
+		{typ: av.AAC, time: 100 * time.Millisecond, data: []byte{1, 2, 3}},
+		{typ: av.AAC, time: 100*time.Millisecond + 1024*time.Second/48000, data: []byte{4, 5}},
+	}
+	if len(pkts) != len(want) {
+		t.Fatalf("包数 %d，期望 %d", len(pkts), len(want))
+	}
+	for i, w := range want {
+		pkt := pkts[i]
+		if pkt.Type != w.typ {
+			t.Errorf("第 %d 个包类型 %v，期望 %v", i+1, pkt.Type, w.typ)
+			continue
+		}
+		if pkt.Time != w.time || pkt.CTime != w.ctime || pkt.IsKeyFrame != w.keyframe {
+			t.Errorf("第 %d 个包: 时间 %v CTime %v 关键帧 %v，期望 %v %v %v", i+1, pkt.Time, pkt.CTime, pkt.IsKeyFrame, w.time, w.ctime, w.keyframe)
+		}
+		if w.data != nil && !bytes.Equal(pkt.Data, w.data) {
+			t.Errorf("第 %d 个包数据 %x，期望 %x", i+1, pkt.Data, w.data)
+		}
+		if w.nalus > 0 {
+			// AUD 被去掉
+			nalus, _ := h264.SplitNALUs(pkt.Data)
+			if len(nalus) != w.nalus {
+				t.Errorf("第 %d 个包 NALU 数 %d，期望 %d", i+1, len(nalus), w.nalus)
+			}
+		}
+	}
+
+	if _, err := h264.FromDecoderConfig(pkts[0].Data); err != nil {
+		t.Errorf("H264 解码配置 %x: %v", pkts[0].Data, err)
+	}
+}
+
+func TestTSDemuxerUnwrap(t *testing.T) {
+	const wrap = int64(1) << 33
+
+	// 两个分片：视频先回绕，音频在第二个分片末尾才回绕
+	segments := [][2][]int64{
+		{{wrap - 9000}, {wrap - 4500}},
+		{{wrap - 3600, 900}, {wrap - 900, 1800}},
+	}
+	wantVideo := []int64{wrap - 9000, wrap - 3600, wrap + 900}
+	wantAudio := []int64{wrap - 4500, wrap - 900, wrap + 1800}
+
+	d := newTSDemuxer()
+	var video, audio []time.Duration
+	for i, seg := range segments {
+		w := newTestTSWriter()
+		w.tables([2]int{testVideoPID, tsStreamTypeH264}, [2]int{testAudioPID, tsStreamTypeAAC})
+		for j := 0; j < max(len(seg[0]), len(seg[1])); j++ {
+			if j < len(seg[0]) {
+				w.pes(testVideoPID, testPES(0xe0, seg[0][j], -1, testH264AccessUnit(i == 0 && j == 0, 10)))
+			}
+			if j < len(seg[1]) {
+				w.pes(testAudioPID, testPES(0xc0, seg[1][j], -1, testADTSFrame([]byte{byte(j)})))
+			}
+		}
+		if err := d.Feed(w.buf.Bytes()); err != nil {
+			t.Fatalf("第 %d 个分片: %v", i+1, err)
+		}
+		for {
+			pkt, ok := d.Next()
+			if !ok {
+				break
+			}
+			switch pkt.Type {
+			case av.H264:
+				video = append(video, pkt.Time)
+			case av.AAC:
+				audio = append(audio, pkt.Time)
+			}
+		}
+	}
+
+	check := func(name string, got []time.Duration, want []int64) {
+		if len(got) != len(want) {
+			t.Errorf("%s 包数 %d，期望 %d", name, len(got), len(want))
+			return
+		}
+		for i := range want {
+			if got[i] != testTSTime(want[i]) {
+				t.Errorf("%s 第 %d 个包时间 %v，期望 %v", name, i+1, got[i], testTSTime(want[i]))
+			}
+		}
+	}
+	check("视频", video, wantVideo)
+	check("音频", audio, wantAudio)
+}
+
+func TestTSDemuxerInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"数据过短", make([]byte, 100)},
+		{"同步字节错误", append([]byte{0x00}, make([]byte, tsPacketSize-1)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := newTSDemuxer().Feed(tt.data); err == nil {
+				t.Error("期望返回错误")
+			}
+		})
+	}
+}
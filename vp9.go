@@ -0,0 +1,127 @@
+package main
+
+import "fmt"
+
+// vp9ProfileName VP9 profile 对应的名称
+func vp9ProfileName(profile int) string {
+	return fmt.Sprintf("Profile %d", profile)
+}
+
+// vp9LevelName VP9 level（10 × 级别，例如 31 = 3.1），0 表示未指定
+func vp9LevelName(level int) string {
+	if level == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d", level/10, level%10)
+}
+
+// parseVP9DecoderConfig 解析 VPCodecConfigurationRecord（vpcC）
+// vpcC 中没有分辨率，分辨率需要从关键帧帧头解析（见 parseVP9KeyFrame）
+func parseVP9DecoderConfig(data []byte) (VideoInfo, error) {
+	// 带 FullBox 的 version(8) + flags(24)（FFmpeg 等写入的格式）
+	if len(data) >= 12 && data[0] == 1 {
+		data = data[4:]
+	}
+	if len(data) < 3 {
+		return VideoInfo{}, fmt.Errorf("VP9 解码配置过短: %d 字节", len(data))
+	}
+
+	profile := int(data[0])
+	level := int(data[1])
+	bitDepth := int(data[2] >> 4)
+	info := VideoInfo{
+		Codec:          "VP9",
+		ProfileIdc:     profile,
+		Profile:        vp9ProfileName(profile),
+		LevelIdc:       level,
+		Level:          vp9LevelName(level),
+		BitDepthLuma:   bitDepth,
+		BitDepthChroma: bitDepth,
+	}
+	// chromaSubsampling：0/1 = 4:2:0，2 = 4:2:2，3 = 4:4:4
+	switch (data[2] >> 1) & 0x07 {
+	case 0, 1:
+		info.ChromaFormat = 1
+	case 2:
+		info.ChromaFormat = 2
+	case 3:
+		info.ChromaFormat = 3
+	}
+	return info, nil
+}
+
+// parseVP9KeyFrame 解析 VP9 关键帧的非压缩帧头，得到 profile、位深、色度格式和分辨率
+// 非关键帧返回 false；帧头中没有 level 信息
+func parseVP9KeyFrame(data []byte) (VideoInfo, bool) {
+	br := newBitReader(data)
+	u := br.u
+
+	if u(2) != 2 { // frame_marker
+		return VideoInfo{}, false
+	}
+	profileLow := int(u(1))
+	profile := int(u(1))<<1 | profileLow
+	if profile == 3 {
+		u(1) // reserved_zero
+	}
+	if u(1) != 0 { // show_existing_frame
+		return VideoInfo{}, false
+	}
+	if u(1) != 0 { // frame_type：0 = KEY_FRAME
+		return VideoInfo{}, false
+	}
+	u(1) // show_frame
+	u(1) // error_resilient_mode
+	// frame_sync_code
+	if u(24) != 0x498342 {
+		return VideoInfo{}, false
+	}
+
+	// color_config
+	bitDepth := 8
+	if profile >= 2 {
+		bitDepth = 10
+		if u(1) != 0 { // ten_or_twelve_bit
+			bitDepth = 12
+		}
+	}
+	subsamplingX, subsamplingY := uint(1), uint(1)
+	if u(3) != 7 { // color_space != CS_RGB
+		u(1) // color_range
+		if profile == 1 || profile == 3 {
+			subsamplingX = u(1)
+			subsamplingY = u(1)
+			u(1) // reserved_zero
+		}
+	} else {
+		subsamplingX, subsamplingY = 0, 0
+		if profile == 1 || profile == 3 {
+			u(1) // reserved_zero
+		}
+	}
+
+	// frame_size
+	width := int(u(16)) + 1
+	height := int(u(16)) + 1
+	if br.err != nil {
+		return VideoInfo{}, false
+	}
+
+	info := VideoInfo{
+		Codec:          "VP9",
+		ProfileIdc:     profile,
+		Profile:        vp9ProfileName(profile),
+		Width:          width,
+		Height:         height,
+		BitDepthLuma:   bitDepth,
+		BitDepthChroma: bitDepth,
+		ChromaFormat:   3,
+	}
+	switch {
+	case subsamplingX != 0 && subsamplingY != 0:
+		info.ChromaFormat = 1
+	case subsamplingX != 0:
+		info.ChromaFormat = 2
+	}
+	return info, true
+}